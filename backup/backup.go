@@ -0,0 +1,297 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const manifestName = "manifest.json"
+
+// Backup is a set of files backed up by a single run of dotbot
+type Backup struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Entries []Entry   `json:"entries"`
+}
+
+type Entry struct {
+	// Path is the original location of the file
+	Path string `json:"path"`
+	// Backup is the location of the file relative to the backup directory
+	Backup    string      `json:"backup"`
+	Mode      os.FileMode `json:"mode"`
+	Size      int64       `json:"size"`
+	ModTime   time.Time   `json:"mod_time"`
+	Time      time.Time   `json:"time"`
+	Directive string      `json:"directive,omitempty"`
+}
+
+var (
+	startTime = time.Now()
+	sessionID = startTime.Format("20060102T150405") + "-" + strconv.Itoa(os.Getpid())
+	// created is true once a backup has been started by the current run
+	created = false
+	// Keep and MaxAge limit automatic pruning, the newest Keep backups and
+	// those newer than MaxAge are never removed automatically
+	Keep   = 10
+	MaxAge = 30 * 24 * time.Hour
+)
+
+// stateDir returns the directory backups are stored under
+var stateDir = store.StateDir
+
+// Dir returns the directory containing all backups
+func Dir() string {
+	return filepath.Join(stateDir(), "backups")
+}
+
+// Save moves the file or directory at path into the backup directory for the
+// current run, recording its original location so it can be restored later
+func Save(path string, directive string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(Dir(), sessionID)
+	rel := mirrorPath(path)
+	dest := filepath.Join(dir, rel)
+	// avoid overwriting files backed up earlier in the same run
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			break
+		}
+		rel = mirrorPath(path) + "." + strconv.Itoa(i)
+		dest = filepath.Join(dir, rel)
+	}
+
+	if store.DryRun {
+		return dest, nil
+	}
+
+	err = mkdirs(filepath.Dir(dest))
+	if err != nil {
+		return "", err
+	}
+	err = utils.MovePath(path, dest)
+	if err != nil {
+		return "", err
+	}
+
+	backup, err := Get(sessionID)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	backup.Entries = append(backup.Entries, Entry{
+		Path:      path,
+		Backup:    rel,
+		Mode:      info.Mode(),
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Time:      time.Now(),
+		Directive: directive,
+	})
+	err = writeManifest(backup)
+	if err != nil {
+		return "", err
+	}
+	created = true
+	return dest, nil
+}
+
+// List returns all backups ordered from oldest to newest
+func List() ([]Backup, error) {
+	entries, err := os.ReadDir(Dir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	backups := make([]Backup, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		backup, err := Get(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.Before(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// Get reads the manifest of a single backup
+func Get(id string) (Backup, error) {
+	backup := Backup{ID: id, Time: startTime}
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return backup, errors.New("invalid backup id '" + id + "'")
+	}
+	data, err := os.ReadFile(filepath.Join(Dir(), id, manifestName))
+	if err != nil {
+		return backup, err
+	}
+	err = json.Unmarshal(data, &backup)
+	return backup, err
+}
+
+// Restore moves files from a backup to their original location, when paths
+// is empty all files are restored. Existing files are only replaced when
+// force is set, in which case they are backed up first.
+func (b Backup) Restore(paths []string, force bool) ([]Entry, error) {
+	restored := make([]Entry, 0, len(b.Entries))
+	for _, entry := range b.Entries {
+		if len(paths) > 0 && !utils.ArrContains(paths, entry.Path) {
+			continue
+		}
+		if _, err := os.Lstat(entry.Path); err == nil {
+			if !force {
+				return restored, errors.New("'" + entry.Path + "' already exists")
+			}
+			_, err := Save(entry.Path, "restore")
+			if err != nil {
+				return restored, err
+			}
+		}
+		if !store.DryRun {
+			err := os.MkdirAll(filepath.Dir(entry.Path), os.ModePerm)
+			if err != nil {
+				return restored, err
+			}
+			err = utils.MovePath(filepath.Join(Dir(), b.ID, entry.Backup), entry.Path)
+			if err != nil {
+				return restored, err
+			}
+		}
+		restored = append(restored, entry)
+	}
+	if len(restored) == len(b.Entries) && !store.DryRun {
+		return restored, b.Remove()
+	}
+	return restored, nil
+}
+
+// Remove deletes the backup and all files in it
+func (b Backup) Remove() error {
+	if store.DryRun {
+		return nil
+	}
+	return os.RemoveAll(filepath.Join(Dir(), b.ID))
+}
+
+// Prune removes all but the newest keep backups, and any backups older than
+// maxAge when it is greater than zero
+func Prune(keep int, maxAge time.Duration) ([]Backup, error) {
+	backups, err := List()
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]Backup, 0)
+	for i, backup := range backups {
+		tooMany := keep >= 0 && len(backups)-i > keep
+		tooOld := maxAge > 0 && time.Since(backup.Time) > maxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		err := backup.Remove()
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
+
+// AutoPrune removes old backups once the current run has made a backup, as
+// directives such as extract with replace back up on every update. Only
+// backups which are both older than MaxAge and not within the newest Keep
+// are removed, the backup of the current run is always kept.
+func AutoPrune() ([]Backup, error) {
+	if !created {
+		return nil, nil
+	}
+	backups, err := List()
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]Backup, 0)
+	for i, backup := range backups {
+		if backup.ID == sessionID || len(backups)-i <= Keep || time.Since(backup.Time) <= MaxAge {
+			continue
+		}
+		err := backup.Remove()
+		if err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
+
+// mirrorPath converts an absolute path into a relative path that mirrors its
+// original location e.g. /etc/hosts -> etc/hosts and C:\file -> C/file
+func mirrorPath(path string) string {
+	volume := filepath.VolumeName(path)
+	path = strings.TrimPrefix(path, volume)
+	volume = strings.Trim(volume, `:\/`)
+	return filepath.Join(volume, strings.TrimLeft(path, `\/`))
+}
+
+func writeManifest(backup Backup) error {
+	data, err := json.MarshalIndent(backup, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(Dir(), backup.ID, manifestName)
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return err
+	}
+	chownSudoUser(path)
+	return nil
+}
+
+// mkdirs creates path and any parents, directories are owned by the user
+// who invoked sudo so that they can prune their own backups
+func mkdirs(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	err := mkdirs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	err = os.Mkdir(path, os.ModePerm)
+	if err != nil && !os.IsExist(err) {
+		return err
+	}
+	chownSudoUser(path)
+	return nil
+}
+
+func chownSudoUser(path string) {
+	uid, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+	if err != nil {
+		return
+	}
+	gid, err := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if err != nil {
+		return
+	}
+	_ = os.Lchown(path, uid, gid)
+}
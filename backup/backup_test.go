@@ -0,0 +1,192 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// useDir stores backups in a new directory and resets the current run
+func useDir(t *testing.T) {
+	dir := t.TempDir()
+	stateDir = func() string { return dir }
+	created = false
+	t.Cleanup(func() {
+		created = false
+	})
+}
+
+// addBackup creates a backup made by an earlier run of a single file
+func addBackup(t *testing.T, id string, age time.Duration, path string) Backup {
+	backup := Backup{ID: id, Time: time.Now().Add(-age), Entries: []Entry{{Path: path, Backup: "file"}}}
+	if err := mkdirs(filepath.Join(Dir(), id)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(Dir(), id, "file"), []byte(id), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeManifest(backup); err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+func readFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func ids(backups []Backup) []string {
+	result := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		result = append(result, backup.ID)
+	}
+	return result
+}
+
+func TestSaveRestore(t *testing.T) {
+	useDir(t)
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest, err := Save(path, "link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("file was not moved into the backup")
+	}
+	if readFile(t, dest) != "original" {
+		t.Errorf("backup does not contain the file")
+	}
+	backup, err := Get(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backup.Entries) != 1 || backup.Entries[0].Path != path || backup.Entries[0].Directive != "link" {
+		t.Fatalf("unexpected manifest entries %+v", backup.Entries)
+	}
+
+	restored, err := backup.Restore(nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 1 || readFile(t, path) != "original" {
+		t.Errorf("file was not restored")
+	}
+	if _, err := os.Stat(filepath.Join(Dir(), sessionID)); !os.IsNotExist(err) {
+		t.Errorf("backup was not removed after restoring all files")
+	}
+}
+
+func TestRestoreExisting(t *testing.T) {
+	useDir(t)
+	path := filepath.Join(t.TempDir(), "config")
+	backup := addBackup(t, "old", time.Hour, path)
+	if err := os.WriteFile(path, []byte("current"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backup.Restore(nil, false); err == nil {
+		t.Errorf("restored over an existing file without force")
+	}
+	if _, err := backup.Restore(nil, true); err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, path) != "old" {
+		t.Errorf("file was not restored")
+	}
+	current, err := Get(sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(current.Entries) != 1 || current.Entries[0].Directive != "restore" {
+		t.Errorf("existing file was not backed up before restoring")
+	}
+}
+
+func TestRestoreOldestWithManyBackups(t *testing.T) {
+	useDir(t)
+	path := filepath.Join(t.TempDir(), "config")
+	oldest := addBackup(t, "0", 100*24*time.Hour, path)
+	for i := 1; i <= Keep; i++ {
+		addBackup(t, strconv.Itoa(i), time.Duration(90-i)*24*time.Hour, path+strconv.Itoa(i))
+	}
+	if err := os.WriteFile(path, []byte("current"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oldest.Restore(nil, true); err != nil {
+		t.Fatalf("Restore() error: %v", err)
+	}
+	if readFile(t, path) != "0" {
+		t.Errorf("oldest backup was not restored")
+	}
+	backups, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the restored backup is removed and the replaced file is backed up
+	if len(backups) != Keep+1 {
+		t.Errorf("backups = %v, want %d backups", ids(backups), Keep+1)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	useDir(t)
+	for i := 0; i < 5; i++ {
+		addBackup(t, strconv.Itoa(i), time.Duration(5-i)*24*time.Hour, "/file")
+	}
+	removed, err := Prune(3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(removed); len(got) != 2 || got[0] != "0" || got[1] != "1" {
+		t.Errorf("Prune(3, 0) removed %v, want [0 1]", got)
+	}
+	removed, err = Prune(-1, 36*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(removed); len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("Prune(-1, 36h) removed %v, want [2 3]", got)
+	}
+}
+
+func TestAutoPrune(t *testing.T) {
+	useDir(t)
+	for i := 0; i < Keep+3; i++ {
+		age := MaxAge + 24*time.Hour
+		if i == 1 {
+			age = time.Hour
+		}
+		addBackup(t, strconv.Itoa(i), age-time.Duration(i)*time.Minute, "/file")
+	}
+	removed, err := AutoPrune()
+	if err != nil || len(removed) != 0 {
+		t.Fatalf("AutoPrune() removed %v %v before the run made a backup", ids(removed), err)
+	}
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Save(path, "extract"); err != nil {
+		t.Fatal(err)
+	}
+	removed, err = AutoPrune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// backups outside the newest Keep are only removed when older than MaxAge
+	if got := ids(removed); len(got) != 4 || got[0] != "0" || got[1] != "2" || got[3] != "4" {
+		t.Errorf("AutoPrune() removed %v, want [0 2 3 4]", got)
+	}
+	if _, err := Get(sessionID); err != nil {
+		t.Errorf("backup of the current run was removed: %v", err)
+	}
+}
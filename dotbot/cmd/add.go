@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/plugins"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var addFlags struct {
+	group  string
+	source string
+}
+
+var addCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Move a file into the dotfiles repo and link it back",
+	Long: `Move a file into the dotfiles repo and link it back
+
+The file is stored at a path mirroring its location, relative to the home
+directory with the leading dot removed e.g. ~/.config/foo.toml is stored at
+config/foo.toml, files outside the home directory are stored under root/.
+A link entry is added to the config, or to the group given with --group.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := filepath.Abs(utils.ExpandUser(args[0]))
+		if err != nil {
+			log.Fatalln(err)
+		}
+		config := readConfig()
+		store.TmplVars(config.Vars)
+
+		pathStat, err := os.Lstat(path)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if config.HasLink(path) {
+			log.Fatalln("path is already linked in the config")
+		}
+		if pathStat.Mode()&os.ModeSymlink != 0 {
+			log.Fatalln("path is already a symlink")
+		}
+
+		source := addFlags.source
+		if source == "" {
+			source = plugins.MirrorSource(path)
+		}
+		sourcePath, err := filepath.Abs(source)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if base, _ := os.Getwd(); strings.HasPrefix(path, base+string(filepath.Separator)) {
+			log.Fatalln("path is already in the dotfiles directory")
+		}
+		if _, err := os.Lstat(sourcePath); err == nil {
+			log.Fatalln("source '" + source + "' already exists in the dotfiles directory")
+		}
+
+		linkPath := utils.ShrinkUser(path)
+		edit, err := plugins.AddLinkEntry(utils.GetConfigPath(), addFlags.group, linkPath, filepath.ToSlash(source))
+		if err != nil {
+			log.Fatalln("failed to add link to config:", err)
+		}
+
+		if !store.DryRun {
+			// the config is saved first so the file is never moved without a link entry
+			err = edit.Save()
+			if err != nil {
+				log.Fatalln("failed to save config:", err)
+			}
+			err = os.MkdirAll(filepath.Dir(sourcePath), os.ModePerm)
+			if err == nil {
+				err = utils.MovePath(path, sourcePath)
+			}
+			if err != nil {
+				revertAdd(edit, "", "")
+				log.Fatalln("failed to move file into dotfiles directory:", err)
+			}
+			err = os.Symlink(sourcePath, path)
+			if err != nil {
+				revertAdd(edit, sourcePath, path)
+				log.Fatalln("failed to create link:", err)
+			}
+		}
+
+		logger := log.NewBasicLogger("ADD")
+		logger.Tag("moved").Path(
+			emerald.HighlightPathStat(linkPath, pathStat),
+			emerald.HighlightPath(source, pathStat.Mode()),
+		)
+		logger.Tag("linked").Path(
+			emerald.HighlightPath(linkPath, os.ModeSymlink),
+			emerald.HighlightPath(source, pathStat.Mode()),
+		)
+		logger.Tag("added").Println(emerald.HighlightPath(edit.Path, 0))
+	},
+}
+
+// revertAdd moves the file back from the dotfiles directory
+// when it was moved and restores the config file
+func revertAdd(edit *plugins.ConfigEdit, sourcePath string, path string) {
+	if sourcePath != "" {
+		if err := utils.MovePath(sourcePath, path); err != nil {
+			log.Errorln("failed to move file back from dotfiles directory:", err)
+		}
+	}
+	if err := edit.Revert(); err != nil {
+		log.Errorln("failed to restore config:", err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(addCmd)
+	addCmd.Flags().StringVarP(&addFlags.group, "group", "g", "", "group to add the link to")
+	addCmd.Flags().StringVar(&addFlags.source, "source", "", "path in the dotfiles repo to store the file at")
+	_ = addCmd.RegisterFlagCompletionFunc("group", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		readConfig()
+		return store.RegisteredGroups, cobra.ShellCompDirectiveNoFileComp
+	})
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/backup"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var backupsFlags struct {
+	json      bool
+	force     bool
+	keep      int
+	olderThan string
+}
+
+var backupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "Manage files backed up by safe_force and replace",
+}
+
+var backupsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List backups and the files they contain",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		backups, err := backup.List()
+		if err != nil {
+			log.Fatalln("failed to list backups:", err)
+		}
+		if backupsFlags.json {
+			if backups == nil {
+				backups = []backup.Backup{}
+			}
+			printJSON(backups)
+			return
+		}
+		for _, b := range backups {
+			emerald.Print(
+				emerald.Bold, emerald.Blue, b.ID, emerald.Reset, emerald.LightBlack,
+				" ", b.Time.Format(time.RFC1123), emerald.Reset, "\n",
+			)
+			for _, entry := range b.Entries {
+				emerald.Print("  ", emerald.HighlightFileMode(entry.Mode), " ", emerald.HighlightPath(utils.ShrinkUser(entry.Path), entry.Mode))
+				if entry.Directive != "" {
+					emerald.Print(emerald.LightBlack, " (", entry.Directive, ")", emerald.Reset)
+				}
+				emerald.Print("\n")
+			}
+		}
+	},
+}
+
+var backupsRestoreCmd = &cobra.Command{
+	Use:   "restore <id> [<path>...]",
+	Short: "Restore files from a backup to their original location",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		b, err := backup.Get(args[0])
+		if err != nil {
+			log.Fatalln("failed to read backup:", err)
+		}
+		paths := make([]string, 0, len(args)-1)
+		for _, path := range args[1:] {
+			path, err := filepath.Abs(utils.ExpandUser(path))
+			if err != nil {
+				log.Fatalln(err)
+			}
+			paths = append(paths, path)
+		}
+		restored, err := b.Restore(paths, backupsFlags.force)
+		for _, entry := range restored {
+			fmt.Print("[restored] ")
+			emerald.Println(emerald.HighlightPath(utils.ShrinkUser(entry.Path), entry.Mode))
+		}
+		if err != nil {
+			log.Fatalln("failed to restore backup:", err)
+		}
+	},
+	ValidArgsFunction: completeBackupIDs,
+}
+
+var backupsPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old backups",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		maxAge, err := parseAge(backupsFlags.olderThan)
+		if err != nil {
+			log.Fatalln("invalid duration:", err)
+		}
+		keep := backupsFlags.keep
+		if !cmd.Flags().Changed("keep") && maxAge > 0 {
+			// only prune by age when just --older-than is given
+			keep = -1
+		}
+		removed, err := backup.Prune(keep, maxAge)
+		for _, b := range removed {
+			fmt.Print("[removed] ")
+			emerald.Println(emerald.Blue + b.ID + emerald.Reset)
+		}
+		if err != nil {
+			log.Fatalln("failed to prune backups:", err)
+		}
+	},
+}
+
+// parseAge parses a duration additionally accepting days e.g. 30d
+func parseAge(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if days := strings.TrimSuffix(s, "d"); days != s {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func completeBackupIDs(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	backups, _ := backup.List()
+	ids := make([]string, 0, len(backups))
+	for _, b := range backups {
+		ids = append(ids, b.ID)
+	}
+	return ids, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(backupsCmd)
+	backupsCmd.AddCommand(backupsListCmd, backupsRestoreCmd, backupsPruneCmd)
+	backupsListCmd.Flags().BoolVar(&backupsFlags.json, "json", false, "output as json")
+	backupsRestoreCmd.Flags().BoolVarP(&backupsFlags.force, "force", "f", false, "back up and replace files that already exist")
+	backupsPruneCmd.Flags().IntVar(&backupsFlags.keep, "keep", backup.Keep, "number of most recent backups to keep")
+	backupsPruneCmd.Flags().StringVar(&backupsFlags.olderThan, "older-than", "", "remove backups older than this e.g. 30d or 12h")
+}
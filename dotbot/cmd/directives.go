@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/plugins"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+)
+
+var directivesFlags struct {
+	json bool
+}
+
+var directivesCmd = &cobra.Command{
+	Use:   "directives",
+	Short: "List available directives",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		directives := plugins.Directives()
+		if directivesFlags.json {
+			printJSON(directives)
+			return
+		}
+		width := 0
+		for _, directive := range directives {
+			if len(directive.Name) > width {
+				width = len(directive.Name)
+			}
+		}
+		for _, directive := range directives {
+			emerald.Print(
+				emerald.Green, fmt.Sprintf("%-*s", width, directive.Name), emerald.Reset,
+				"  ", directive.Desc, "\n",
+			)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(directivesCmd)
+	directivesCmd.Flags().BoolVar(&directivesFlags.json, "json", false, "output as json")
+}
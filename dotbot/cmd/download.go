@@ -13,9 +13,13 @@ import (
 )
 
 var dwFlags struct {
-	Executable bool
-	Force      bool
-	Paths      []string
+	Executable  bool
+	Force       bool
+	Paths       []string
+	Sha256      string
+	ChecksumUrl string
+	Signature   string
+	PublicKey   string
 }
 
 var downloadCmd = &cobra.Command{
@@ -48,6 +52,9 @@ var downloadCmd = &cobra.Command{
 }
 
 func downloadFiles(urls []string) {
+	if dwFlags.Sha256 != "" && len(urls) > 1 {
+		log.Fatalln("--sha256 can only be used when downloading a single file, use --checksum-url instead")
+	}
 	var mode utils.WeakFileMode = 0666
 	if dwFlags.Executable {
 		mode = 0777
@@ -67,11 +74,15 @@ func downloadFiles(urls []string) {
 
 func downloadFile(url string, path string, mode utils.WeakFileMode) {
 	dl := plugins.DownloadConfig{
-		Url:    url,
-		Path:   path,
-		Mode:   mode,
-		Force:  dwFlags.Force,
-		Mkdirs: true,
+		Url:         url,
+		Path:        path,
+		Mode:        mode,
+		Force:       dwFlags.Force,
+		Mkdirs:      true,
+		Sha256:      dwFlags.Sha256,
+		ChecksumUrl: dwFlags.ChecksumUrl,
+		Signature:   dwFlags.Signature,
+		PublicKey:   dwFlags.PublicKey,
 	}
 	err := dl.Run()
 	if err != nil {
@@ -84,4 +95,8 @@ func init() {
 	downloadCmd.Flags().BoolVarP(&dwFlags.Executable, "executable", "x", false, "make downloaded file executable")
 	downloadCmd.Flags().BoolVarP(&dwFlags.Force, "force", "f", false, "overwrite destination file if it exists")
 	downloadCmd.Flags().StringSliceVarP(&dwFlags.Paths, "output", "o", nil, "destination to download file to")
+	downloadCmd.Flags().StringVar(&dwFlags.Sha256, "sha256", "", "expected sha256 checksum of the file")
+	downloadCmd.Flags().StringVar(&dwFlags.ChecksumUrl, "checksum-url", "", "url of a checksum file listing the file by name")
+	downloadCmd.Flags().StringVar(&dwFlags.Signature, "signature", "", "url of a minisign signature of the file, or of the checksum file if given")
+	downloadCmd.Flags().StringVar(&dwFlags.PublicKey, "public-key", "", "minisign public key the signature must be made by")
 }
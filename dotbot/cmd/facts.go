@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/facts"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+)
+
+var factsFlags struct {
+	json bool
+}
+
+var factsCmd = &cobra.Command{
+	Use:   "facts [<name>...]",
+	Short: "Show facts detected about the system",
+	Long: `Show facts detected about the system
+
+Facts can be used in templates as .Facts and matched by the system directive.
+A fact can be overridden for testing with --fact name=value or by setting
+the environment variable ` + facts.EnvPrefix + `<NAME>.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		names := args
+		if len(names) == 0 {
+			for _, fact := range facts.List() {
+				names = append(names, fact.Name)
+			}
+		}
+		values := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			value, ok := facts.Get(name)
+			if !ok {
+				log.Fatalln("unknown fact '" + name + "'")
+			}
+			values[name] = value
+		}
+		if factsFlags.json {
+			printJSON(values)
+			return
+		}
+		if len(args) == 1 {
+			fmt.Println(values[args[0]])
+			return
+		}
+		width := 0
+		for _, name := range names {
+			if len(name) > width {
+				width = len(name)
+			}
+		}
+		for _, name := range names {
+			emerald.Print(
+				emerald.Green, fmt.Sprintf("%-*s", width, name), emerald.Reset,
+				"  ", emerald.Blue, values[name], emerald.Reset, "\n",
+			)
+		}
+	},
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		names := make([]string, 0, len(facts.List()))
+		for _, fact := range facts.List() {
+			names = append(names, fact.Name+"\t"+fact.Desc)
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(factsCmd)
+	factsCmd.Flags().BoolVar(&factsFlags.json, "json", false, "output as json")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/plugins"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var groupsFlags struct {
+	json bool
+}
+
+var groupsCmd = &cobra.Command{
+	Use:   "groups",
+	Short: "List groups defined in the dotbot config",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		groups := readConfig().Config.GroupTree()
+		if groupsFlags.json {
+			printJSON(groups)
+			return
+		}
+		printGroups(groups, 0)
+	},
+}
+
+func printGroups(groups []plugins.GroupInfo, depth int) {
+	for _, group := range groups {
+		emerald.Print(
+			strings.Repeat("  ", depth), emerald.Bold, emerald.Blue, group.Name, emerald.Reset,
+			emerald.LightBlack, fmt.Sprintf(" (%d)", group.Directives), emerald.Reset, "\n",
+		)
+		printGroups(group.Groups, depth+1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(groupsCmd)
+	groupsCmd.Flags().BoolVar(&groupsFlags.json, "json", false, "output as json")
+}
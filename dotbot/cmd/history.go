@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+	"sort"
+	"time"
+)
+
+var historyFlags struct {
+	json  bool
+	limit int
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history [<tool>]",
+	Short: "Show when tools were installed and updated",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		tool := ""
+		if len(args) > 0 {
+			tool = args[0]
+		}
+		events, err := store.History(tool)
+		if err != nil {
+			log.Fatalln("failed to read history:", err)
+		}
+		if historyFlags.limit > 0 && len(events) > historyFlags.limit {
+			events = events[len(events)-historyFlags.limit:]
+		}
+		if historyFlags.json {
+			if events == nil {
+				events = []store.Event{}
+			}
+			printJSON(events)
+			return
+		}
+		for _, event := range events {
+			emerald.Print(emerald.LightBlack, event.Time.Local().Format(time.RFC1123), emerald.Reset, " ")
+			if event.Success {
+				emerald.Print(emerald.Green, "[ok]     ")
+			} else {
+				emerald.Print(emerald.Red, "[failed] ")
+			}
+			emerald.Print(emerald.Green, event.Tool, emerald.Reset, " ")
+			if event.Previous != "" {
+				emerald.Print(emerald.Blue, event.Previous, emerald.LightBlack, " -> ")
+			}
+			emerald.Print(emerald.LightBlue, event.Version, emerald.Reset)
+			emerald.Print(emerald.ColorCode("cyan+d"), " [", utils.FormatDuration(event.Duration), "]", emerald.Reset, "\n")
+			if event.Error != "" {
+				emerald.Print("  ", emerald.Red, event.Error, emerald.Reset, "\n")
+			}
+		}
+	},
+	ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		events, _ := store.History("")
+		tools := make([]string, 0, 8)
+		for _, event := range events {
+			if !utils.ArrContains(tools, event.Tool) {
+				tools = append(tools, event.Tool)
+			}
+		}
+		sort.Strings(tools)
+		return tools, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().BoolVar(&historyFlags.json, "json", false, "output as json")
+	historyCmd.Flags().IntVarP(&historyFlags.limit, "limit", "n", 0, "only show the most recent events")
+}
@@ -10,12 +10,20 @@ import (
 	"strings"
 )
 
-var apply = false
+var initFlags struct {
+	apply    bool
+	add      bool
+	priority int
+}
 
 var initCmd = &cobra.Command{
 	Use:   "init <owner>[/<repo>]",
 	Short: "Clone and setup a dotbot dotfiles repo",
-	Args:  cobra.MinimumNArgs(1),
+	Long: "Clone and setup a dotbot dotfiles repo.\n\n" +
+		"Use --add to add the repo as an additional source e.g. a shared team repo alongside\n" +
+		"your personal dotfiles. Sources are applied from the lowest to the highest priority,\n" +
+		"the dotfiles directory has a priority of 0 and vars from later sources take precedence.",
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		repo := args[0]
 		// assume repo is named dotfiles
@@ -41,9 +49,17 @@ var initCmd = &cobra.Command{
 			log.Panicln("failed to get current directory", err)
 		}
 
-		store.SetSave("directory", filepath.Join(cwd, name))
+		path := filepath.Join(cwd, name)
+		if _, configured := store.Settings.HasGet("directory"); initFlags.add && configured {
+			err = store.AddSource(store.Source{Path: path, Priority: initFlags.priority})
+		} else {
+			err = store.Settings.SetSave("directory", path)
+		}
+		if err != nil {
+			log.Fatalln("failed to save dotfiles directory", err)
+		}
 
-		if apply {
+		if initFlags.apply {
 			_ = os.Setenv("DOTBOT_NO_UPDATE_REPO", "1")
 			rootCmd.Run(rootCmd, nil)
 		}
@@ -52,5 +68,7 @@ var initCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(initCmd)
-	initCmd.Flags().BoolVar(&apply, "apply", false, "run dotbot immediately after cloning")
+	initCmd.Flags().BoolVar(&initFlags.apply, "apply", false, "run dotbot immediately after cloning")
+	initCmd.Flags().BoolVar(&initFlags.add, "add", false, "add the repo as an additional source instead of replacing the dotfiles directory")
+	initCmd.Flags().IntVar(&initFlags.priority, "priority", 10, "priority of an additional source, lower priorities are applied first")
 }
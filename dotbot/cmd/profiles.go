@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+)
+
+var profilesFlags struct {
+	json bool
+}
+
+type profileInfo struct {
+	Name     string   `json:"name"`
+	Groups   []string `json:"groups"`
+	Missing  []string `json:"missing,omitempty"`
+	Default  bool     `json:"default"`
+	Template string   `json:"template,omitempty"`
+	Expr     string   `json:"expr,omitempty"`
+}
+
+var profilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List profiles and the groups they select",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		store.TmplVars(config.Vars)
+		match, _ := config.DefaultProfile.Match()
+
+		profiles := make([]profileInfo, 0, len(config.Profiles))
+		for _, profile := range config.Profiles {
+			info := profileInfo{
+				Name:    profile.Name,
+				Groups:  profile.Groups,
+				Default: profile.Name == match.Profile,
+			}
+			if info.Default {
+				info.Template = match.Template
+				if match.Expr != nil {
+					info.Expr = match.Expr.String()
+				}
+			}
+			for _, group := range profile.Groups {
+				if !utils.ArrContains(store.RegisteredGroups, group) {
+					info.Missing = append(info.Missing, group)
+				}
+			}
+			profiles = append(profiles, info)
+		}
+
+		if profilesFlags.json {
+			printJSON(profiles)
+			return
+		}
+		for _, profile := range profiles {
+			emerald.Print(emerald.Red, profile.Name, emerald.Reset, ";")
+			for _, group := range profile.Groups {
+				if utils.ArrContains(profile.Missing, group) {
+					emerald.Print(" ", emerald.LightBlack, group, "?")
+				} else {
+					emerald.Print(" ", emerald.Cyan, group)
+				}
+			}
+			if profile.Default {
+				emerald.Print(" ", emerald.LightYellow, "(default)")
+				if profile.Template != "" {
+					emerald.Print(" ", emerald.LightBlack, profile.Template)
+				}
+				if profile.Expr != "" {
+					emerald.Print(" ", emerald.LightBlack, profile.Expr)
+				}
+			}
+			emerald.Print(emerald.Reset, "\n")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(profilesCmd)
+	profilesCmd.Flags().BoolVar(&profilesFlags.json, "json", false, "output as json")
+}
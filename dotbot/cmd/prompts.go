@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/plugins"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+)
+
+var promptsFlags struct {
+	json bool
+}
+
+type promptInfo struct {
+	Name   string `json:"name"`
+	Answer string `json:"answer"`
+}
+
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "Manage saved answers to prompts",
+}
+
+var promptsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved answers, passwords are hidden",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		passwords := passwordPrompts()
+		prompts := make([]promptInfo, 0, 4)
+		for _, name := range store.Prompts.Keys() {
+			answer, _ := store.Prompts.HasGet(name)
+			if utils.ArrContains(passwords, name) {
+				answer = "********"
+			}
+			prompts = append(prompts, promptInfo{name, answer})
+		}
+		if promptsFlags.json {
+			printJSON(prompts)
+			return
+		}
+		for _, prompt := range prompts {
+			emerald.Print(emerald.Cyan, prompt.Name, emerald.Reset, ": ", prompt.Answer, "\n")
+		}
+	},
+}
+
+var promptsResetCmd = &cobra.Command{
+	Use:   "reset [<name>...]",
+	Short: "Remove saved answers so they are asked again on the next run, defaults to all",
+	Run: func(cmd *cobra.Command, args []string) {
+		names := args
+		if len(names) == 0 {
+			names = store.Prompts.Keys()
+		}
+		for _, name := range names {
+			if _, ok := store.Prompts.HasGet(name); !ok {
+				log.Fatalln("no saved answer for prompt", name)
+			}
+		}
+		for _, name := range names {
+			store.Prompts.Unset(name)
+		}
+		if err := store.Save(); err != nil {
+			log.Fatalln("failed to save state file:", err)
+		}
+		for _, name := range names {
+			fmt.Print("[reset] ")
+			emerald.Println(emerald.Cyan + name + emerald.Reset)
+		}
+	},
+	ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		names := make([]string, 0, 4)
+		for _, name := range store.Prompts.Keys() {
+			if !utils.ArrContains(args, name) {
+				names = append(names, name)
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+// passwordPrompts returns the names of password prompts in the config
+func passwordPrompts() []string {
+	names := make([]string, 0, 2)
+	readConfig().Config.Walk(func(key string, plugin plugins.Plugin) {
+		if b, ok := plugin.(*plugins.PromptBase); ok {
+			for _, c := range *b {
+				if c.Type == utils.PromptPassword {
+					names = append(names, c.Name)
+				}
+			}
+		}
+	})
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(promptsCmd)
+	promptsCmd.AddCommand(promptsListCmd, promptsResetCmd)
+	promptsListCmd.Flags().BoolVar(&promptsFlags.json, "json", false, "output as json")
+}
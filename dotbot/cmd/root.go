@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/jcwillox/dotbot/facts"
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/plugins"
 	"github.com/jcwillox/dotbot/store"
@@ -9,12 +12,14 @@ import (
 	"github.com/jcwillox/emerald"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
 )
 
 var (
-	color  string
-	dryRun bool
-	debug  bool
+	color         string
+	dryRun        bool
+	debug         bool
+	factOverrides []string
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -23,26 +28,103 @@ var rootCmd = &cobra.Command{
 	Short:   "A powerful bootstrapping utility for your dotfiles and system",
 	Version: store.Version,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := utils.ChBaseDir()
-		if err != nil {
-			log.Fatalln(err)
+		sources := store.Sources()
+		if len(sources) == 0 {
+			log.Fatalln("dotfiles directory is not configured")
 		}
-		path := utils.GetConfigPath()
-		if loadRunConfig(path) {
-			fmt.Println("reloading configuration...")
-			loadRunConfig(path)
+		// vars, templates and profiles of all sources are merged before any
+		// are run, so higher priority sources apply to lower priority ones
+		var shared *plugins.Config
+		if len(sources) > 1 {
+			merged := readConfig()
+			shared = &merged
+		}
+		cliGroups := store.Groups != nil
+		for _, source := range sources {
+			store.SetSource(source.Path)
+			err := utils.ChBaseDir()
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if len(sources) > 1 {
+				emerald.Print(emerald.Bold, "[source] ", emerald.Reset, emerald.HighlightPath(utils.ShrinkUser(source.Path), os.ModeDir), "\n")
+			}
+			path := utils.GetConfigPath()
+			if loadRunConfig(path, shared) {
+				fmt.Println("reloading configuration...")
+				if !cliGroups {
+					store.Groups = nil
+				}
+				if shared != nil {
+					merged := readConfig()
+					shared = &merged
+					store.SetSource(source.Path)
+					if err := utils.ChBaseDir(); err != nil {
+						log.Fatalln(err)
+					}
+				}
+				loadRunConfig(path, shared)
+			}
 		}
 	},
 }
 
-func loadRunConfig(path string) bool {
+func loadRunConfig(path string, shared *plugins.Config) bool {
 	config, err := plugins.ReadConfig(path)
 	if err != nil {
 		log.Fatalln("config file not found:", err)
 	}
+	if shared != nil {
+		config.Share(*shared)
+	}
 	return config.RunAll()
 }
 
+// readConfig reads the config from the dotfiles directory without running it,
+// the configs of additional sources are merged into it in priority order
+func readConfig() plugins.Config {
+	var config plugins.Config
+	directory := store.Settings.Get("directory")
+	for _, source := range store.Sources() {
+		store.SetSource(source.Path)
+		other, err := readSourceConfig()
+		if err != nil && source.Path == directory {
+			log.Fatalln(err)
+		} else if err != nil {
+			log.Warnln("failed to read config of source", source.Path+":", err)
+			continue
+		}
+		config.Merge(other)
+	}
+	// remain in the dotfiles directory e.g. for editing its config
+	store.SetSource(directory)
+	err := utils.ChBaseDir()
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return config
+}
+
+func readSourceConfig() (plugins.Config, error) {
+	err := utils.ChBaseDir()
+	if err != nil {
+		return plugins.Config{}, err
+	}
+	config, err := plugins.ReadConfig(utils.GetConfigPath())
+	if err != nil {
+		return plugins.Config{}, errors.New("config file not found: " + err.Error())
+	}
+	return config, nil
+}
+
+func printJSON(v interface{}) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatalln("failed to marshal json output", err)
+	}
+	fmt.Println(string(data))
+}
+
 func Execute() error {
 	return rootCmd.Execute()
 }
@@ -54,14 +136,16 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&color, "color", "auto", "when to use colors (always, auto, never)")
 	rootCmd.PersistentFlags().BoolP("help", "h", false, "help for dotbot")
 	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "enable dry run mode")
+	rootCmd.PersistentFlags().BoolVar(&store.Adopt, "adopt", store.Adopt, "move existing files into the dotfiles directory when linking")
 	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "enable debugging output")
+	rootCmd.PersistentFlags().StringArrayVar(&factOverrides, "fact", nil, "override a fact for testing e.g. --fact family=debian")
 
 	_ = rootCmd.RegisterFlagCompletionFunc("color", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return []string{"auto", "always", "never"}, cobra.ShellCompDirectiveNoFileComp
 	})
 	_ = rootCmd.RegisterFlagCompletionFunc("group", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-		if base, present := store.HasGet("directory"); present {
-			err := os.Chdir(base)
+		for _, source := range store.Sources() {
+			err := os.Chdir(source.Path)
 			if err == nil {
 				path := utils.GetConfigPath()
 				_, _ = plugins.ReadConfig(path)
@@ -82,6 +166,14 @@ func initConfig() {
 		emerald.SetColorState(false)
 	}
 
+	for _, override := range factOverrides {
+		name, value, _ := strings.Cut(override, "=")
+		err := facts.Override(name, value)
+		if err != nil {
+			log.Fatalln("invalid fact override:", err)
+		}
+	}
+
 	if rootCmd.PersistentFlags().Changed("dry-run") {
 		store.DryRun = dryRun
 	}
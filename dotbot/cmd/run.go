@@ -5,6 +5,7 @@ import (
 	"github.com/jcwillox/dotbot/plugins"
 	"github.com/jcwillox/dotbot/template"
 	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/utils/sudo"
 	"github.com/jcwillox/emerald"
 	"github.com/k0kubun/pp/v3"
 	"github.com/spf13/cobra"
@@ -16,6 +17,7 @@ import (
 var runFlags struct {
 	fromStdin bool
 	file      string
+	listFuncs bool
 }
 
 var runCmd = &cobra.Command{
@@ -26,6 +28,10 @@ var runCmd = &cobra.Command{
 		if len(args) > 0 {
 			// special case to allow easily testing templates
 			if args[0] == "template" {
+				if runFlags.listFuncs {
+					printFuncs()
+					return
+				}
 				if len(args) < 2 {
 					fmt.Println("No template provided!")
 					os.Exit(1)
@@ -56,6 +62,10 @@ var runCmd = &cobra.Command{
 					log.Fatalln("Failed parsing config from std-input", err)
 				}
 				config.RunAll(true)
+				// let dotbot know that tasks failed when it is run using sudo
+				if plugins.FailedTasks() > 0 {
+					os.Exit(sudo.FailedExitCode)
+				}
 			} else if runFlags.file != "" {
 				config, err := plugins.ReadConfig(runFlags.file)
 				if err != nil {
@@ -67,8 +77,25 @@ var runCmd = &cobra.Command{
 	},
 }
 
+func printFuncs() {
+	functions := template.Functions()
+	width := 0
+	for _, function := range functions {
+		if len(function.Name) > width {
+			width = len(function.Name)
+		}
+	}
+	for _, function := range functions {
+		emerald.Print(
+			emerald.Green, fmt.Sprintf("%-*s", width, function.Name), emerald.Reset,
+			"  ", function.Desc, "\n",
+		)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().BoolVar(&runFlags.fromStdin, "stdin", false, "read config from std-input")
 	runCmd.Flags().StringVarP(&runFlags.file, "file", "f", "", "run specified config file")
+	runCmd.Flags().BoolVar(&runFlags.listFuncs, "list-funcs", false, "list functions available in templates")
 }
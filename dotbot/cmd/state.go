@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/plugins"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var stateFlags struct {
+	json          bool
+	replace       bool
+	showPasswords bool
+	reinstall     []string
+}
+
+type stateEntry struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Inspect and modify the per-user dotbot state file",
+	Long: "Inspect and modify the per-user dotbot state file.\n\n" +
+		"Entries are addressed as <namespace>.<key> e.g. settings.directory or installs.fzf,\n" +
+		"the namespaces are " + strings.Join(store.Namespaces, ", ") + ".",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(stateFlags.reinstall) == 0 {
+			_ = cmd.Help()
+			return
+		}
+		for _, name := range stateFlags.reinstall {
+			key, ok := installKey(name)
+			if !ok {
+				log.Fatalln("no recorded install for", name)
+			}
+			store.ClearInstall(key)
+		}
+		if err := store.Save(); err != nil {
+			log.Fatalln("failed to save state file:", err)
+		}
+		for _, name := range stateFlags.reinstall {
+			fmt.Print("[reinstall] ")
+			emerald.Println(emerald.Green + name + emerald.Reset)
+		}
+	},
+}
+
+var stateListCmd = &cobra.Command{
+	Use:   "list [<namespace>...]",
+	Short: "List entries in the state file, defaults to all namespaces",
+	Run: func(cmd *cobra.Command, args []string) {
+		namespaces := args
+		if len(namespaces) == 0 {
+			namespaces = store.Namespaces
+		}
+		entries := make([]stateEntry, 0, 16)
+		for _, namespace := range namespaces {
+			values, err := stateEntries(namespace)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			keys := make([]string, 0, len(values))
+			for key := range values {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				entries = append(entries, stateEntry{namespace, key, values[key]})
+			}
+		}
+		if stateFlags.json {
+			printJSON(entries)
+			return
+		}
+		for _, entry := range entries {
+			emerald.Print(emerald.LightBlack, entry.Namespace, ".", emerald.Cyan, entry.Key, emerald.Reset, " ", formatStateValue(entry.Value), "\n")
+		}
+	},
+	ValidArgsFunction: func(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+		namespaces := make([]string, 0, len(store.Namespaces))
+		for _, namespace := range store.Namespaces {
+			if !utils.ArrContains(args, namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		return namespaces, cobra.ShellCompDirectiveNoFileComp
+	},
+}
+
+var stateGetCmd = &cobra.Command{
+	Use:   "get <namespace>.<key>",
+	Short: "Print the value of an entry",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		namespace, key := splitStateKey(args[0])
+		values, err := stateEntries(namespace)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		value, ok := values[key]
+		if !ok {
+			log.Fatalln("no entry for", args[0])
+		}
+		if stateFlags.json {
+			printJSON(value)
+		} else if install, ok := value.(store.Install); ok {
+			fmt.Println(install.Version)
+		} else {
+			fmt.Println(value)
+		}
+	},
+	ValidArgsFunction: completeStateKeys,
+}
+
+var stateUnsetCmd = &cobra.Command{
+	Use:   "unset <namespace>.<key>...",
+	Short: "Remove entries from the state file",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		for _, arg := range args {
+			present, err := store.UnsetEntry(splitStateKey(arg))
+			if err != nil {
+				log.Fatalln(err)
+			} else if !present {
+				log.Fatalln("no entry for", arg)
+			}
+		}
+		if err := store.Save(); err != nil {
+			log.Fatalln("failed to save state file:", err)
+		}
+		for _, arg := range args {
+			fmt.Print("[unset] ")
+			emerald.Println(emerald.Cyan + arg + emerald.Reset)
+		}
+	},
+	ValidArgsFunction: completeStateKeys,
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export [<file>]",
+	Short: "Write the state file as json to a file or stdout",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		omit := hiddenPrompts()
+		if len(omit) > 0 {
+			log.Warnln("omitting answers to password prompts, use --show-passwords to include them")
+		}
+		data, err := store.Export(omit)
+		if err != nil {
+			log.Fatalln("failed to export state:", err)
+		}
+		if len(args) == 0 || args[0] == "-" {
+			fmt.Println(string(data))
+			return
+		}
+		err = os.WriteFile(utils.ExpandUser(args[0]), append(data, '\n'), 0600)
+		if err != nil {
+			log.Fatalln("failed to export state:", err)
+		}
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Merge an exported state file into the state file, use - to read from stdin",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var data []byte
+		var err error
+		if args[0] == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(utils.ExpandUser(args[0]))
+		}
+		if err != nil {
+			log.Fatalln("failed to read state:", err)
+		}
+		err = store.Import(data, stateFlags.replace)
+		if err != nil {
+			log.Fatalln("failed to import state:", err)
+		}
+	},
+}
+
+// installKey returns the key an install is recorded under, installs migrated from
+// older state files are keyed by url so the name is looked up in the config
+func installKey(name string) (string, bool) {
+	if _, ok := store.GetInstall(name); ok {
+		return name, true
+	}
+	key, found := "", false
+	readConfig().Config.Walk(func(_ string, plugin plugins.Plugin) {
+		if b, ok := plugin.(*plugins.InstallBase); ok && !found {
+			for _, c := range *b {
+				if c.String() != name {
+					continue
+				}
+				for _, k := range c.StateKeys() {
+					if _, ok := store.GetInstall(k); ok {
+						key, found = k, true
+						return
+					}
+				}
+			}
+		}
+	})
+	return key, found
+}
+
+// hiddenPrompts returns the names of prompts whose answers are hidden unless
+// --show-passwords is given, all answers are hidden if the config cannot be read
+func hiddenPrompts() []string {
+	if stateFlags.showPasswords {
+		return nil
+	}
+	if _, err := readSourceConfig(); err != nil {
+		return store.Prompts.Keys()
+	}
+	return passwordPrompts()
+}
+
+// stateEntries returns the entries of a namespace with the answers to password prompts masked
+func stateEntries(namespace string) (map[string]interface{}, error) {
+	values, err := store.Entries(namespace)
+	if err != nil || namespace != "prompts" {
+		return values, err
+	}
+	for _, name := range hiddenPrompts() {
+		if _, ok := values[name]; ok {
+			values[name] = "********"
+		}
+	}
+	return values, nil
+}
+
+// splitStateKey splits <namespace>.<key> at the first dot, as
+// keys such as paths may contain dots themselves
+func splitStateKey(arg string) (string, string) {
+	namespace, key, found := strings.Cut(arg, ".")
+	if !found {
+		log.Fatalln("expected <namespace>.<key> but got", arg)
+	}
+	return namespace, key
+}
+
+func formatStateValue(value interface{}) string {
+	if install, ok := value.(store.Install); ok {
+		s := install.Version
+		if install.Time != nil {
+			s += " (" + install.Time.Format("2006-01-02 15:04") + ")"
+		}
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func completeStateKeys(_ *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+	keys := make([]string, 0, 16)
+	for _, namespace := range store.Namespaces {
+		values, _ := store.Entries(namespace)
+		for key := range values {
+			if arg := namespace + "." + key; !utils.ArrContains(args, arg) {
+				keys = append(keys, arg)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	rootCmd.AddCommand(stateCmd)
+	stateCmd.AddCommand(stateListCmd, stateGetCmd, stateUnsetCmd, stateExportCmd, stateImportCmd)
+	stateCmd.Flags().StringArrayVar(&stateFlags.reinstall, "reinstall", nil, "clear the recorded version of an install by name so it is installed again")
+	stateListCmd.Flags().BoolVar(&stateFlags.json, "json", false, "output as json")
+	stateGetCmd.Flags().BoolVar(&stateFlags.json, "json", false, "output as json")
+	for _, cmd := range []*cobra.Command{stateListCmd, stateGetCmd, stateExportCmd} {
+		cmd.Flags().BoolVar(&stateFlags.showPasswords, "show-passwords", false, "include the answers to password prompts")
+	}
+	stateImportCmd.Flags().BoolVar(&stateFlags.replace, "replace", false, "replace the state file instead of merging")
+	_ = stateCmd.RegisterFlagCompletionFunc("reinstall", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return store.Installs(), cobra.ShellCompDirectiveNoFileComp
+	})
+}
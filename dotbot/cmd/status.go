@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/emerald"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/execabs"
 	"log"
@@ -10,17 +12,33 @@ import (
 
 var statusCmd = &cobra.Command{
 	Use:   "status",
-	Short: "Run git status in dotfiles directory",
+	Short: "Run git status in each dotfiles directory",
 	Run: func(_ *cobra.Command, args []string) {
-		cmd := execabs.Command("git", "-C", store.BaseDir(), "status", "-s")
+		err := runGitInSources("status", "-s")
+		if err != nil {
+			log.Fatalln("failed running git status command:", err)
+		}
+	},
+}
+
+// runGitInSources runs git in each source, printing
+// the path of each source when there are several
+func runGitInSources(args ...string) error {
+	sources := store.Sources()
+	for _, source := range sources {
+		if len(sources) > 1 {
+			emerald.Print(emerald.Bold, "[source] ", emerald.Reset, emerald.HighlightPath(utils.ShrinkUser(source.Path), os.ModeDir), "\n")
+		}
+		cmd := execabs.Command("git", append([]string{"-C", source.Path}, args...)...)
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
 		cmd.Stdin = os.Stdin
 		err := cmd.Run()
 		if err != nil {
-			log.Fatalln("failed running git status command:", err)
+			return err
 		}
-	},
+	}
+	return nil
 }
 
 func init() {
@@ -0,0 +1,405 @@
+package expr
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/utils"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type nameNode struct {
+	name string
+	pos  int
+}
+
+type listNode struct {
+	items []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+	pos         int
+}
+
+type ternaryNode struct {
+	cond, then, els node
+}
+
+type memberNode struct {
+	object, key node
+	pos         int
+}
+
+type callNode struct {
+	name string
+	fn   func(args []interface{}) (interface{}, error)
+	args []node
+	pos  int
+}
+
+func (n *literalNode) eval(Env) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *nameNode) eval(env Env) (interface{}, error) {
+	value, ok := env(n.name)
+	if !ok {
+		return nil, fmt.Errorf("column %d: unknown name '%s'", n.pos+1, n.name)
+	}
+	return value, nil
+}
+
+func (n *listNode) eval(env Env) (interface{}, error) {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+func (n *unaryNode) eval(env Env) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "-" {
+		f, ok := toNumber(value)
+		if !ok {
+			return nil, fmt.Errorf("cannot negate %s", describe(value))
+		}
+		return -f, nil
+	}
+	return !truthy(value), nil
+}
+
+func (n *ternaryNode) eval(env Env) (interface{}, error) {
+	cond, err := n.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return n.then.eval(env)
+	}
+	return n.els.eval(env)
+}
+
+func (n *memberNode) eval(env Env) (interface{}, error) {
+	object, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch object := object.(type) {
+	case Object:
+		value, ok := object.Get(fmt.Sprint(key))
+		if !ok {
+			return nil, fmt.Errorf("column %d: unknown field '%v'", n.pos+1, key)
+		}
+		return value, nil
+	case nil:
+		return nil, fmt.Errorf("column %d: cannot get '%v' of nil", n.pos+1, key)
+	}
+	value := reflect.ValueOf(object)
+	switch value.Kind() {
+	case reflect.Map:
+		item := value.MapIndex(reflect.ValueOf(fmt.Sprint(key)))
+		if !item.IsValid() {
+			// missing keys are nil so they can be checked e.g. vars.x != nil
+			return nil, nil
+		}
+		return item.Interface(), nil
+	case reflect.Slice, reflect.Array:
+		f, ok := toNumber(key)
+		if !ok || int(f) < 0 || int(f) >= value.Len() {
+			return nil, fmt.Errorf("column %d: index %v out of range", n.pos+1, key)
+		}
+		return value.Index(int(f)).Interface(), nil
+	}
+	return nil, fmt.Errorf("column %d: cannot get '%v' of %s", n.pos+1, key, describe(object))
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	value, err := n.fn(args)
+	if err != nil {
+		return nil, fmt.Errorf("column %d: %s: %s", n.pos+1, n.name, err)
+	}
+	return value, nil
+}
+
+func (n *binaryNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	// short circuit
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(env)
+		return truthy(right), err
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(env)
+		return truthy(right), err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	value, err := binary(n.op, left, right)
+	if err != nil {
+		return nil, fmt.Errorf("column %d: %s", n.pos+1, err)
+	}
+	return value, nil
+}
+
+func binary(op string, left, right interface{}) (interface{}, error) {
+	switch op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		c, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "in":
+		return contains(right, left)
+	case "contains":
+		return contains(left, right)
+	case "startsWith":
+		return strings.HasPrefix(toString(left), toString(right)), nil
+	case "endsWith":
+		return strings.HasSuffix(toString(left), toString(right)), nil
+	case "matches":
+		return regexp.MatchString(toString(right), toString(left))
+	case "+":
+		if l, ok := left.(string); ok {
+			return l + toString(right), nil
+		}
+	}
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("cannot use '%s' with %s and %s", op, describe(left), describe(right))
+	}
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		return l / r, nil
+	case "%":
+		if int64(r) == 0 {
+			// the operands are truncated to integers so e.g. 0.5 is also zero
+			return nil, fmt.Errorf("division by zero in %s %% %s", toString(l), toString(r))
+		}
+		return float64(int64(l) % int64(r)), nil
+	}
+	return nil, fmt.Errorf("unknown operator '%s'", op)
+}
+
+func equal(left, right interface{}) bool {
+	if lv, ok := left.(utils.Version); ok {
+		c, err := compare(lv, right)
+		return err == nil && c == 0
+	}
+	if rv, ok := right.(utils.Version); ok {
+		c, err := compare(left, rv)
+		return err == nil && c == 0
+	}
+	l, lok := toNumber(left)
+	r, rok := toNumber(right)
+	if lok && rok {
+		return l == r
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compare orders numbers, strings and versions, if either side is a
+// version the other side is parsed as a version
+func compare(left, right interface{}) (int, error) {
+	_, lok := left.(utils.Version)
+	_, rok := right.(utils.Version)
+	if lok || rok {
+		l, err := toVersion(left)
+		if err != nil {
+			return 0, err
+		}
+		r, err := toVersion(right)
+		if err != nil {
+			return 0, err
+		}
+		return l.Compare(r), nil
+	}
+	if l, ok := toNumber(left); ok {
+		if r, ok := toNumber(right); ok {
+			switch {
+			case l < r:
+				return -1, nil
+			case l > r:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	l, lok := left.(string)
+	r, rok := right.(string)
+	if lok && rok {
+		return strings.Compare(l, r), nil
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s", describe(left), describe(right))
+}
+
+func contains(collection, item interface{}) (bool, error) {
+	switch c := collection.(type) {
+	case string:
+		return strings.Contains(c, toString(item)), nil
+	case Object:
+		_, ok := c.Get(toString(item))
+		return ok, nil
+	case nil:
+		return false, nil
+	}
+	value := reflect.ValueOf(collection)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if equal(value.Index(i).Interface(), item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Map:
+		return value.MapIndex(reflect.ValueOf(toString(item))).IsValid(), nil
+	}
+	return false, fmt.Errorf("cannot check if %s contains a value", describe(collection))
+}
+
+func truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	if f, ok := toNumber(value); ok {
+		return f != 0
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func toVersion(value interface{}) (utils.Version, error) {
+	if v, ok := value.(utils.Version); ok {
+		return v, nil
+	}
+	return utils.ParseVersion(toString(value))
+}
+
+func describe(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case utils.Version:
+		return "version"
+	case Object:
+		return "object"
+	}
+	if _, ok := toNumber(value); ok {
+		return "number"
+	}
+	return reflect.TypeOf(value).Kind().String()
+}
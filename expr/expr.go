@@ -0,0 +1,184 @@
+// Package expr evaluates boolean expressions with access to facts and vars
+// e.g. os == "linux" && !wsl && semver(distro.version) >= "22.04"
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/facts"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Env resolves a name used in an expression
+type Env func(name string) (interface{}, bool)
+
+// Object is a value whose fields are resolved when they are used
+type Object interface {
+	Get(key string) (interface{}, bool)
+}
+
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression, errors include the column of the problem
+func Compile(source string) (*Program, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, errorAt(t, "expected end of expression")
+	}
+	return &Program{source, root}, nil
+}
+
+func (p *Program) String() string {
+	return p.source
+}
+
+// Run evaluates the expression using the given env
+func (p *Program) Run(env Env) (interface{}, error) {
+	return p.root.eval(env)
+}
+
+// RunBool evaluates the expression using the default env,
+// it is an error if the result is not a boolean
+func (p *Program) RunBool() (bool, error) {
+	value, err := p.Run(DefaultEnv)
+	if err != nil {
+		return false, err
+	}
+	result, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression must be true or false but was %s", describe(value))
+	}
+	return result, nil
+}
+
+// DefaultEnv resolves facts by name, the distro as distro, all facts as facts
+// and template vars as vars, other names are looked up in the template vars
+func DefaultEnv(name string) (interface{}, bool) {
+	switch name {
+	case "vars":
+		return store.GetVars(), true
+	case "facts":
+		return factsObject{}, true
+	case "distro":
+		return distroObject{}, true
+	}
+	if value, ok := facts.Get(name); ok {
+		return value, true
+	}
+	return store.GetVar(name)
+}
+
+type factsObject struct{}
+
+func (factsObject) Get(key string) (interface{}, bool) {
+	return facts.Get(key)
+}
+
+type distroObject struct{}
+
+func (distroObject) Get(key string) (interface{}, bool) {
+	switch key {
+	case "id", "version", "codename", "name":
+		return facts.String("distro_" + key), true
+	case "like":
+		return strings.Fields(facts.String("distro_like")), true
+	case "pretty_name":
+		return facts.String("distro"), true
+	}
+	return nil, false
+}
+
+var builtins = map[string]func(args []interface{}) (interface{}, error){
+	"semver": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		return utils.ParseVersion(toString(args[0]))
+	},
+	"satisfies": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 2); err != nil {
+			return nil, err
+		}
+		return utils.CheckConstraint(toString(args[1]), toString(args[0]))
+	},
+	"len": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		value := reflect.ValueOf(args[0])
+		switch value.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			return float64(value.Len()), nil
+		}
+		return nil, errors.New("cannot get length of " + describe(args[0]))
+	},
+	"lower": stringFunc(strings.ToLower),
+	"upper": stringFunc(strings.ToUpper),
+	"trim":  stringFunc(strings.TrimSpace),
+	"string": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		return toString(args[0]), nil
+	},
+	"number": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		if f, ok := toNumber(args[0]); ok {
+			return f, nil
+		}
+		return strconv.ParseFloat(strings.TrimSpace(toString(args[0])), 64)
+	},
+	"env": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		return os.Getenv(toString(args[0])), nil
+	},
+	"exists": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		_, err := os.Stat(utils.ExpandUser(toString(args[0])))
+		return err == nil, nil
+	},
+	"onPath": func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		return utils.OnPath(toString(args[0])), nil
+	},
+}
+
+func stringFunc(fn func(string) string) func(args []interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if err := arity(args, 1); err != nil {
+			return nil, err
+		}
+		return fn(toString(args[0])), nil
+	}
+}
+
+func arity(args []interface{}, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %d arguments but got %d", n, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package expr
+
+import (
+	"strings"
+	"testing"
+)
+
+type testObject map[string]interface{}
+
+func (o testObject) Get(key string) (interface{}, bool) {
+	value, ok := o[key]
+	return value, ok
+}
+
+var testVars = map[string]interface{}{
+	"os":     "linux",
+	"wsl":    false,
+	"count":  3,
+	"empty":  nil,
+	"groups": []interface{}{"work", "home"},
+	"user": map[string]interface{}{
+		"name":  "bob",
+		"shell": "zsh",
+	},
+	"distro": testObject{"id": "ubuntu", "version": "22.04"},
+}
+
+func testEnv(name string) (interface{}, bool) {
+	value, ok := testVars[name]
+	return value, ok
+}
+
+func run(t *testing.T, source string) (interface{}, error) {
+	t.Helper()
+	program, err := Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	return program.Run(testEnv)
+}
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		// precedence
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"7 % 4 * 2", 6.0},
+		{"-2 * 3", -6.0},
+		{"1 + 1 == 2 && 3 > 2", true},
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!false && true", true},
+		{"not wsl and os == 'linux'", true},
+		{"1 < 2 == true", true},
+		// in and not in
+		{`"work" in groups`, true},
+		{`"play" in groups`, false},
+		{`"play" not in groups`, true},
+		{`"work" not in groups`, false},
+		{`"play" ! in groups`, true},
+		{`"name" in user`, true},
+		{`"id" in distro`, true},
+		{`"x" in empty`, false},
+		{`"bun" in "ubuntu"`, true},
+		{`groups contains "home"`, true},
+		// ternaries
+		{`os == "linux" ? "yes" : "no"`, "yes"},
+		{`wsl ? "yes" : "no"`, "no"},
+		{`wsl ? 1 : count > 2 ? 2 : 3`, 2.0},
+		{`(wsl ? 1 : 2) + 1`, 3.0},
+		// member access
+		{"user.name", "bob"},
+		{`user["shell"]`, "zsh"},
+		{"user.missing", nil},
+		{"user.missing == nil", true},
+		{"groups[1]", "home"},
+		{"distro.id", "ubuntu"},
+		{`semver(distro.version) >= "20.04"`, true},
+		// strings and functions
+		{`"a" + 1`, "a1"},
+		{`os startsWith "li" && os endsWith "ux"`, true},
+		{`os matches "^l.n"`, true},
+		{`len(groups) == 2`, true},
+		{`upper(user.name)`, "BOB"},
+		{"count == 3.0", true},
+		{"5 % 3", 2.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			got, err := run(t, tt.source)
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		// compile errors
+		{`os == "linux`, "column 7: unterminated string"},
+		{"os # 1", "column 4: unexpected character '#'"},
+		{"os ==", "column 6: expected a value but reached end of expression"},
+		{"(1 + 2", "column 7: expected ')' but reached end of expression"},
+		{"1 2", "column 3: expected end of expression, found '2'"},
+		{"user.1", "column 6: expected a name after '.', found '1'"},
+		{"missing(1)", "column 1: unknown function 'missing'"},
+		{"true ? 1", "column 9: expected ':' but reached end of expression"},
+		// evaluation errors
+		{"unknown == 1", "column 1: unknown name 'unknown'"},
+		{"empty.name", "column 7: cannot get 'name' of nil"},
+		{"user.name.first", "column 11: cannot get 'first' of string"},
+		{"distro.missing", "column 8: unknown field 'missing'"},
+		{"groups[5]", "column 7: index 5 out of range"},
+		{"1 - 'a'", "column 3: cannot use '-' with number and string"},
+		{"5 % 0", "column 3: division by zero in 5 % 0"},
+		{"5 % 0.5", "column 3: division by zero in 5 % 0.5"},
+		{"len(1, 2)", "column 1: len: expected 1 arguments but got 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := run(t, tt.source)
+			if err == nil {
+				t.Fatalf("expected error %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	for _, source := range []string{"false && unknown", "true || unknown", "wsl ? unknown : 1"} {
+		if _, err := run(t, source); err != nil {
+			t.Errorf("%s: unexpected error %v", source, err)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// operators longest first so that e.g. ">=" is matched before ">"
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",", ".", "?", ":"}
+
+// keywords that are used as operators
+var keywordOps = map[string]string{
+	"and":        "&&",
+	"or":         "||",
+	"not":        "!",
+	"in":         "in",
+	"matches":    "matches",
+	"contains":   "contains",
+	"startsWith": "startsWith",
+	"endsWith":   "endsWith",
+}
+
+func lex(input string) ([]token, error) {
+	tokens := make([]token, 0, 16)
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start:i]), start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := string(runes[start:i])
+			if op, ok := keywordOps[word]; ok {
+				tokens = append(tokens, token{tokenOp, op, start})
+			} else {
+				tokens = append(tokens, token{tokenIdent, word, start})
+			}
+		case r == '"' || r == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(runes[i])
+					}
+					continue
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("column %d: unterminated string", start+1)
+			}
+			i++
+			tokens = append(tokens, token{tokenString, sb.String(), start})
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:]), op) {
+					tokens = append(tokens, token{tokenOp, op, i})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("column %d: unexpected character '%c'", i+1, r)
+			}
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(runes)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(values ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return false
+	}
+	for _, value := range values {
+		if t.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	t := p.next()
+	if t.kind != tokenOp || t.value != op {
+		return errorAt(t, "expected '"+op+"'")
+	}
+	return nil
+}
+
+func errorAt(t token, msg string) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("column %d: %s but reached end of expression", t.pos+1, msg)
+	}
+	return fmt.Errorf("column %d: %s, found '%s'", t.pos+1, msg, t.value)
+}
+
+// binary operator precedence, higher binds tighter
+var precedence = map[string]int{
+	"||":         1,
+	"&&":         2,
+	"==":         3,
+	"!=":         3,
+	"<":          4,
+	"<=":         4,
+	">":          4,
+	">=":         4,
+	"in":         4,
+	"matches":    4,
+	"contains":   4,
+	"startsWith": 4,
+	"endsWith":   4,
+	"+":          5,
+	"-":          5,
+	"*":          6,
+	"/":          6,
+	"%":          6,
+}
+
+func (p *parser) parseExpr() (node, error) {
+	cond, err := p.parseBinary(1)
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("?") {
+		return cond, nil
+	}
+	p.next()
+	then, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return &ternaryNode{cond, then, els}, nil
+}
+
+func (p *parser) parseBinary(minPrec int) (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		negate := false
+		if t.kind == tokenOp && t.value == "!" && p.tokens[p.pos+1].value == "in" {
+			// not in
+			negate = true
+			t = p.tokens[p.pos+1]
+		}
+		prec, ok := precedence[t.value]
+		if t.kind != tokenOp || !ok || prec < minPrec {
+			return left, nil
+		}
+		p.next()
+		if negate {
+			p.next()
+		}
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{t.value, left, right, t.pos}
+		if negate {
+			left = &unaryNode{"!", left}
+		}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("!", "-") {
+		op := p.next().value
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op, operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isOp("."):
+			p.next()
+			t := p.next()
+			if t.kind != tokenIdent {
+				return nil, errorAt(t, "expected a name after '.'")
+			}
+			n = &memberNode{n, &literalNode{t.value}, t.pos}
+		case p.isOp("["):
+			t := p.next()
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			n = &memberNode{n, index, t.pos}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		value, err := strconv.ParseFloat(strings.ReplaceAll(t.value, "_", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("column %d: invalid number '%s'", t.pos+1, t.value)
+		}
+		return &literalNode{value}, nil
+	case tokenString:
+		return &literalNode{t.value}, nil
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "nil", "null":
+			return &literalNode{nil}, nil
+		}
+		if p.isOp("(") {
+			return p.parseCall(t)
+		}
+		return &nameNode{t.value, t.pos}, nil
+	case tokenOp:
+		switch t.value {
+		case "(":
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.parseList("]")
+			if err != nil {
+				return nil, err
+			}
+			return &listNode{items}, nil
+		}
+	}
+	return nil, errorAt(t, "expected a value")
+}
+
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := builtins[name.value]
+	if !ok {
+		return nil, fmt.Errorf("column %d: unknown function '%s'", name.pos+1, name.value)
+	}
+	p.next()
+	args, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	return &callNode{name.value, fn, args, name.pos}, nil
+}
+
+// parseList parses comma separated expressions up to the closing token
+func (p *parser) parseList(end string) ([]node, error) {
+	items := make([]node, 0, 2)
+	if p.isOp(end) {
+		p.next()
+		return items, nil
+	}
+	for {
+		item, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.isOp(",") {
+			p.next()
+			continue
+		}
+		return items, p.expect(end)
+	}
+}
@@ -0,0 +1,82 @@
+package facts
+
+import (
+	"bytes"
+	"github.com/shirou/gopsutil/host"
+	"github.com/shirou/gopsutil/mem"
+	"os"
+	"os/user"
+)
+
+var platform, family string
+
+func platformInfo() (string, string) {
+	if platform != "" {
+		return platform, family
+	}
+	platform, family, _, _ = host.PlatformInformation()
+	return platform, family
+}
+
+func kernelVersion() string {
+	version, _ := host.KernelVersion()
+	return version
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+	return name
+}
+
+func username() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func totalMemory() uint64 {
+	stat, err := mem.VirtualMemory()
+	if err != nil {
+		return 0
+	}
+	return stat.Total
+}
+
+func container() string {
+	// set by systemd-nspawn, podman and lxc
+	if name := os.Getenv("container"); name != "" {
+		return name
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if _, err := os.Stat("/run/.containerenv"); err == nil {
+		return "podman"
+	}
+	data, _ := os.ReadFile("/proc/1/cgroup")
+	for _, name := range []string{"docker", "kubepods", "lxc", "containerd"} {
+		if bytes.Contains(data, []byte(name)) {
+			return name
+		}
+	}
+	return ""
+}
+
+var containerSystems = []string{"docker", "lxc", "podman", "rkt", "systemd-nspawn", "openvz", "linux-vserver"}
+
+func virtualization() string {
+	system, role, err := host.Virtualization()
+	if err != nil || role != "guest" {
+		return ""
+	}
+	for _, name := range containerSystems {
+		if system == name {
+			return ""
+		}
+	}
+	return system
+}
@@ -0,0 +1,68 @@
+package facts
+
+import "strings"
+
+// release is the parsed os-release of the system
+type release struct {
+	ID         string
+	Like       string
+	VersionID  string
+	Codename   string
+	Name       string
+	PrettyName string
+}
+
+var cachedRelease *release
+
+func getCachedRelease() release {
+	if cachedRelease == nil {
+		r := getRelease()
+		cachedRelease = &r
+	}
+	return *cachedRelease
+}
+
+// MatchDistro returns true if the distro starts with name, ignoring case
+func MatchDistro(name string) bool {
+	distro := Distro()
+	minLen := min(len(distro), len(name))
+	return strings.EqualFold(distro[:minLen], name[:minLen])
+}
+
+// Distro returns the display name of the distro
+func Distro() string {
+	return String("distro")
+}
+
+// MatchDistroLike returns true if the distro is or is derived from any of the
+// given distros, this uses the distro_id and distro_like facts
+func MatchDistroLike(ids []string) bool {
+	like := append([]string{String("distro_id")}, strings.Fields(String("distro_like"))...)
+	for _, id := range ids {
+		for _, l := range like {
+			if strings.EqualFold(id, l) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DistroInfo exposes the distro to templates as .Distro, it is
+// displayed as the pretty name e.g. {{ .Distro.ID }}, {{ .Distro }}
+type DistroInfo struct{}
+
+func (DistroInfo) ID() string         { return String("distro_id") }
+func (DistroInfo) Like() []string     { return strings.Fields(String("distro_like")) }
+func (DistroInfo) VersionID() string  { return String("distro_version") }
+func (DistroInfo) Codename() string   { return String("distro_codename") }
+func (DistroInfo) Name() string       { return String("distro_name") }
+func (DistroInfo) PrettyName() string { return String("distro") }
+func (DistroInfo) String() string     { return String("distro") }
+
+func min(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
@@ -0,0 +1,31 @@
+//go:build !linux
+// +build !linux
+
+package facts
+
+import (
+	"github.com/shirou/gopsutil/host"
+	"strings"
+)
+
+func getDistro() string {
+	platform, _, _, err := host.PlatformInformation()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(platform, "Microsoft ")
+}
+
+func getRelease() release {
+	platform, family, version, _ := host.PlatformInformation()
+	r := release{
+		ID:         strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(platform, "Microsoft "), " ", "_")),
+		VersionID:  version,
+		Name:       strings.TrimPrefix(platform, "Microsoft "),
+		PrettyName: getDistro(),
+	}
+	if family != "" && family != platform {
+		r.Like = family
+	}
+	return r
+}
@@ -0,0 +1,138 @@
+//go:build !windows
+// +build !windows
+
+package facts
+
+import (
+	"bufio"
+	"bytes"
+	"golang.org/x/sys/execabs"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// readRelease parses a file of KEY=value lines such as /etc/os-release
+func readRelease(path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		} else {
+			value = strings.Trim(value, "\"'")
+		}
+		values[key] = value
+	}
+	return values
+}
+
+func getPrettyName(path string) string {
+	values := readRelease(path)
+	if name := values["PRETTY_NAME"]; name != "" {
+		return name
+	}
+	return values["DISTRIB_DESCRIPTION"]
+}
+
+func getRelease() release {
+	values := readRelease("/etc/os-release")
+	if values == nil {
+		values = readRelease("/usr/lib/os-release")
+	}
+	if values == nil {
+		// e.g. openwrt
+		values = readRelease("/etc/openwrt_release")
+		return release{
+			ID:         strings.ToLower(values["DISTRIB_ID"]),
+			VersionID:  values["DISTRIB_RELEASE"],
+			Codename:   values["DISTRIB_CODENAME"],
+			Name:       values["DISTRIB_ID"],
+			PrettyName: values["DISTRIB_DESCRIPTION"],
+		}
+	}
+	codename := values["VERSION_CODENAME"]
+	if codename == "" {
+		codename = values["UBUNTU_CODENAME"]
+	}
+	return release{
+		ID:         values["ID"],
+		Like:       values["ID_LIKE"],
+		VersionID:  values["VERSION_ID"],
+		Codename:   codename,
+		Name:       values["NAME"],
+		PrettyName: values["PRETTY_NAME"],
+	}
+}
+
+func getProxmoxVersion() string {
+	if _, err := exec.LookPath("pveversion"); err != nil {
+		return ""
+	}
+	cmd := execabs.Command("dpkg", "-s", "pve-manager")
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		reader := bufio.NewReader(stdout)
+		err = cmd.Start()
+		if err == nil {
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					break
+				}
+				if strings.HasPrefix(line, "Version:") {
+					return "Proxmox VE " + strings.TrimRight(strings.TrimPrefix(line, "Version: "), "\n")
+				}
+			}
+		}
+	}
+	return "Proxmox VE"
+}
+
+func getDistro() string {
+
+	name := getProxmoxVersion()
+	if name != "" {
+		return name
+	}
+	name = getPrettyName("/etc/lsb-release")
+	if name != "" {
+		return name
+	}
+	out, err := execabs.Command("lsb_release", "-sd").Output()
+	if err == nil {
+		return string(bytes.TrimRight(out, "\n"))
+	}
+	name = getPrettyName("/etc/os-release")
+	if name != "" {
+		return name
+	}
+	name = getPrettyName("/usr/lib/os-release")
+	if name != "" {
+		return name
+	}
+	name = getPrettyName("/etc/openwrt_release")
+	if name != "" {
+		return name
+	}
+	out, err = execabs.Command("uname", "-o").Output()
+	if err == nil {
+		return string(bytes.TrimRight(out, "\n"))
+	}
+	return runtime.GOOS
+}
@@ -0,0 +1,161 @@
+package facts
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/utils/sudo"
+	"os"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+type Fact struct {
+	Name string `json:"name"`
+	Desc string `json:"description"`
+	fn   interface{}
+}
+
+// facts are detected the first time they are used, each fn returns a single
+// string, bool, int or uint64 value which can be overridden for testing
+var facts = []Fact{
+	{"os", "Operating system e.g. linux, windows, darwin", func() string { return runtime.GOOS }},
+	{"arch", "Architecture e.g. amd64, arm64", func() string { return runtime.GOARCH }},
+	{"distro", "Display name of the distro or OS e.g. Ubuntu 22.04.1 LTS", getDistro},
+	{"distro_id", "Distro ID from os-release e.g. ubuntu, debian, arch", func() string { return getCachedRelease().ID }},
+	{"distro_like", "Space separated IDs of distros this is derived from e.g. debian", func() string { return getCachedRelease().Like }},
+	{"distro_version", "Distro version e.g. 22.04", func() string { return getCachedRelease().VersionID }},
+	{"distro_codename", "Distro codename e.g. jammy", func() string { return getCachedRelease().Codename }},
+	{"distro_name", "Distro name without the version e.g. Ubuntu", func() string { return getCachedRelease().Name }},
+	{"platform", "Platform name e.g. ubuntu, arch, darwin", func() string {
+		platform, _ := platformInfo()
+		return platform
+	}},
+	{"family", "Platform family e.g. debian, rhel, arch", func() string {
+		_, family := platformInfo()
+		return family
+	}},
+	{"libc", "System libc either gnu or musl", utils.GetLibc},
+	{"kernel", "Kernel version", kernelVersion},
+	{"hostname", "Hostname of the machine", hostname},
+	{"user", "Name of the current user", username},
+	{"home", "Home directory of the current user", func() string { return store.HomeDirectory }},
+	{"shell", "Default shell of the current user", utils.DefaultShell},
+	{"wsl", "True if running under WSL", utils.IsWSL},
+	{"container", "Container runtime when running in a container e.g. docker, podman, lxc", container},
+	{"virtualization", "Hypervisor when running in a virtual machine e.g. kvm, vmware, hyperv", virtualization},
+	{"cpu_count", "Number of logical CPUs", runtime.NumCPU},
+	{"memory", "Total memory in bytes", totalMemory},
+	{"is_root", "True if running as root", sudo.IsRoot},
+	{"can_sudo", "True if the user can use sudo", sudo.CanSudo},
+}
+
+var values = make(map[string]interface{})
+
+// EnvPrefix is prepended to the upper case name of a fact to override it
+const EnvPrefix = "DOTBOT_FACT_"
+
+// List returns all facts in the order they are defined
+func List() []Fact {
+	return facts
+}
+
+func lookup(name string) (Fact, bool) {
+	for _, fact := range facts {
+		if fact.Name == name {
+			return fact, true
+		}
+	}
+	return Fact{}, false
+}
+
+// Exists returns true if there is a fact with the given name
+func Exists(name string) bool {
+	_, ok := lookup(name)
+	return ok
+}
+
+// Get returns the value of a fact, detecting it if this is the first use
+func Get(name string) (interface{}, bool) {
+	if value, ok := values[name]; ok {
+		return value, true
+	}
+	fact, ok := lookup(name)
+	if !ok {
+		return nil, false
+	}
+	if override, ok := os.LookupEnv(EnvPrefix + strings.ToUpper(name)); ok {
+		value, err := fact.parse(override)
+		if err == nil {
+			values[name] = value
+			return value, true
+		}
+		log.Warnln("ignoring invalid override for fact", name+":", err)
+	}
+	value := reflect.ValueOf(fact.fn).Call(nil)[0].Interface()
+	values[name] = value
+	return value, true
+}
+
+// All returns the value of every fact
+func All() map[string]interface{} {
+	all := make(map[string]interface{}, len(facts))
+	for _, fact := range facts {
+		all[fact.Name], _ = Get(fact.Name)
+	}
+	return all
+}
+
+// Override replaces the value of a fact, the override is also set in
+// the environment so that it applies to dotbot when run using sudo
+func Override(name string, value string) error {
+	fact, ok := lookup(name)
+	if !ok {
+		return errors.New("unknown fact '" + name + "'")
+	}
+	parsed, err := fact.parse(value)
+	if err != nil {
+		return err
+	}
+	values[name] = parsed
+	return os.Setenv(EnvPrefix+strings.ToUpper(name), value)
+}
+
+// parse converts a string to the type returned by the fact
+func (f Fact) parse(value string) (interface{}, error) {
+	switch reflect.TypeOf(f.fn).Out(0).Kind() {
+	case reflect.Bool:
+		return strconv.ParseBool(value)
+	case reflect.Int:
+		return strconv.Atoi(value)
+	case reflect.Uint64:
+		return strconv.ParseUint(value, 10, 64)
+	}
+	return value, nil
+}
+
+func String(name string) string {
+	value, _ := Get(name)
+	if value == nil {
+		return ""
+	} else if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+func Bool(name string) bool {
+	value, _ := Get(name)
+	b, _ := value.(bool)
+	return b
+}
+
+func Int(name string) int {
+	value, _ := Get(name)
+	i, _ := value.(int)
+	return i
+}
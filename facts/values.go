@@ -0,0 +1,39 @@
+package facts
+
+// Values exposes facts to templates as .Facts, each fact is
+// only detected when it is used e.g. {{ .Facts.Hostname }}
+type Values struct{}
+
+func (Values) Get(name string) interface{} {
+	value, _ := Get(name)
+	return value
+}
+
+func (Values) OS() string             { return String("os") }
+func (Values) Arch() string           { return String("arch") }
+func (Values) Distro() string         { return String("distro") }
+func (Values) DistroID() string       { return String("distro_id") }
+func (Values) DistroLike() string     { return String("distro_like") }
+func (Values) DistroVersion() string  { return String("distro_version") }
+func (Values) DistroCodename() string { return String("distro_codename") }
+func (Values) DistroName() string     { return String("distro_name") }
+func (Values) Platform() string       { return String("platform") }
+func (Values) Family() string         { return String("family") }
+func (Values) Libc() string           { return String("libc") }
+func (Values) Kernel() string         { return String("kernel") }
+func (Values) Hostname() string       { return String("hostname") }
+func (Values) User() string           { return String("user") }
+func (Values) Home() string           { return String("home") }
+func (Values) Shell() string          { return String("shell") }
+func (Values) WSL() bool              { return Bool("wsl") }
+func (Values) Container() string      { return String("container") }
+func (Values) Virtualization() string { return String("virtualization") }
+func (Values) CPUCount() int          { return Int("cpu_count") }
+func (Values) IsRoot() bool           { return Bool("is_root") }
+func (Values) CanSudo() bool          { return Bool("can_sudo") }
+
+func (Values) Memory() uint64 {
+	value, _ := Get("memory")
+	memory, _ := value.(uint64)
+	return memory
+}
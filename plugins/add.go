@@ -0,0 +1,327 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/template"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/yamltools"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigEdit is a modification to a config file that has not been saved yet,
+// the new text is inserted so the formatting and comments of the file are kept
+type ConfigEdit struct {
+	Path  string
+	doc   *yaml.Node
+	data  []byte
+	lines []string
+	// line is the number of lines before the inserted text
+	line int
+	text string
+}
+
+// HasLink returns true if a link directive in the config creates a link at path
+func (c Config) HasLink(path string) bool {
+	found := false
+	c.Config.Walk(func(key string, plugin Plugin) {
+		if b, ok := plugin.(*LinkBase); ok {
+			for _, config := range *b {
+				linkPath := config.Path
+				if template.RenderField(&linkPath) != nil {
+					continue
+				}
+				linkPath, err := filepath.Abs(utils.ExpandUser(linkPath))
+				if err == nil && linkPath == path {
+					found = true
+				}
+			}
+		}
+	})
+	return found
+}
+
+// MirrorSource returns the path a file should be stored at in the dotfiles
+// directory, files in the home directory have the leading dot removed
+// e.g. ~/.config/foo.toml -> config/foo.toml, /etc/foo -> root/etc/foo
+func MirrorSource(path string) string {
+	home, _ := os.UserHomeDir()
+	if rel, err := filepath.Rel(home, path); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		return strings.TrimPrefix(filepath.ToSlash(rel), ".")
+	}
+	volume := filepath.VolumeName(path)
+	path = strings.TrimPrefix(path, volume)
+	return "root/" + strings.ReplaceAll(volume, ":", "") + strings.TrimPrefix(filepath.ToSlash(path), "/")
+}
+
+// AddLinkEntry inserts a link from path to source into the config file, if a
+// group is specified the link is added to that group, the returned edit
+// is the file that was modified which may be a file included by the config
+func AddLinkEntry(configPath string, group string, path string, source string) (*ConfigEdit, error) {
+	edit, err := loadConfigEdit(configPath)
+	if err != nil {
+		return nil, err
+	}
+	entry := formatScalar(path) + ": " + formatScalar(source) + "\n"
+	root := edit.doc.Content[0]
+	if root.Kind == yaml.MappingNode {
+		key, list := configEntry(root)
+		if group == "" && list == nil {
+			edit.insertAfter(root, -1, "config:\n"+linkDirective("  - ", entry))
+			return edit, edit.validate()
+		} else if group == "" && list.Tag == "!!null" {
+			indent := strings.Repeat(" ", key.Column+1)
+			edit.insertAfter(key, key.Column-1, linkDirective(indent+"- ", entry))
+			return edit, edit.validate()
+		}
+		if list != nil {
+			edit, root, err = edit.resolve(list)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if group != "" {
+		edit, root, err = edit.findGroup(root, group)
+		if err != nil {
+			return nil, err
+		}
+		if root == nil {
+			return nil, errors.New("group '" + group + "' not found")
+		}
+	}
+	if root.Kind != yaml.SequenceNode {
+		return nil, errors.New("unable to add link to '" + edit.Path + "' as the config is not a list")
+	}
+	err = edit.insertLink(root, entry)
+	if err != nil {
+		return nil, err
+	}
+	return edit, edit.validate()
+}
+
+// Save writes the config file with the inserted text
+func (e *ConfigEdit) Save() error {
+	return os.WriteFile(e.Path, e.content(), 0644)
+}
+
+// Revert writes the config file as it was before it was saved
+func (e *ConfigEdit) Revert() error {
+	return os.WriteFile(e.Path, e.data, 0644)
+}
+
+func loadConfigEdit(path string) (*ConfigEdit, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{}
+	err = yaml.Unmarshal(data, doc)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		// empty file
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{
+			{Kind: yaml.SequenceNode, Tag: "!!seq"},
+		}}
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return &ConfigEdit{Path: path, doc: doc, data: data, lines: lines}, nil
+}
+
+// resolve follows an !include tag returning the included file and its content
+func (e *ConfigEdit) resolve(n *yaml.Node) (*ConfigEdit, *yaml.Node, error) {
+	if n.Tag != "!include" {
+		return e, n, nil
+	}
+	edit, err := loadConfigEdit(n.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return edit, edit.doc.Content[0], nil
+}
+
+// findGroup searches the list and any nested lists for the config of a group
+func (e *ConfigEdit) findGroup(n *yaml.Node, name string) (*ConfigEdit, *yaml.Node, error) {
+	edit, n, err := e.resolve(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			edit, config, err := edit.findGroup(item, name)
+			if err != nil || config != nil {
+				return edit, config, err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Value == "group" {
+				edit, config, err := edit.groupConfig(value, name)
+				if err != nil || config != nil {
+					return edit, config, err
+				}
+			}
+			edit, config, err := edit.findGroup(value, name)
+			if err != nil || config != nil {
+				return edit, config, err
+			}
+		}
+	}
+	return edit, nil, nil
+}
+
+// groupConfig returns the config of the named group from a group directive
+func (e *ConfigEdit) groupConfig(n *yaml.Node, name string) (*ConfigEdit, *yaml.Node, error) {
+	edit, n, err := e.resolve(n)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n.Tag == "!include_dir_named" {
+		entries, err := os.ReadDir(n.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())) == name {
+				return edit.resolve(&yaml.Node{Tag: "!include", Value: filepath.Join(n.Value, entry.Name())})
+			}
+		}
+		return edit, nil, nil
+	}
+	groups := []*yaml.Node{n}
+	if n.Kind == yaml.SequenceNode {
+		groups = n.Content
+	}
+	for _, group := range groups {
+		if group.Kind != yaml.MappingNode {
+			continue
+		}
+		for i := 0; i < len(group.Content); i += 2 {
+			if group.Content[i].Value == name {
+				return edit.resolve(group.Content[i+1])
+			}
+		}
+	}
+	return edit, nil, nil
+}
+
+// insertLink adds the link entry to the first link directive in the list
+// or appends a new link directive if there are none
+func (e *ConfigEdit) insertLink(list *yaml.Node, entry string) error {
+	for _, item := range list.Content {
+		links := yamltools.MapValue(item, "link")
+		if links == nil || links.Style&yaml.FlowStyle != 0 || len(links.Content) == 0 {
+			continue
+		}
+		switch links.Kind {
+		case yaml.MappingNode:
+			indent := links.Content[0].Column - 1
+			e.insertAfter(links, indent, strings.Repeat(" ", indent)+entry)
+			return nil
+		case yaml.SequenceNode:
+			if prefix, ok := e.itemPrefix(links.Content[0]); ok {
+				e.insertAfter(links, strings.Index(prefix, "-"), prefix+entry)
+				return nil
+			}
+		}
+	}
+	if list.Line == 0 {
+		// the file is empty
+		e.line, e.text = len(e.lines), linkDirective("- ", entry)
+		return nil
+	}
+	if list.Style&yaml.FlowStyle == 0 && len(list.Content) > 0 {
+		if prefix, ok := e.itemPrefix(list.Content[0]); ok {
+			e.insertAfter(list, strings.Index(prefix, "-"), linkDirective(prefix, entry))
+			return nil
+		}
+	}
+	return fmt.Errorf("unable to add link to '%s' as the list on line %d is not a block list", e.Path, list.Line)
+}
+
+// insertAfter inserts text after the lines of the node, including any
+// following lines indented more than indent which continue its last value
+func (e *ConfigEdit) insertAfter(n *yaml.Node, indent int, text string) {
+	line := lastLine(n)
+	for line < len(e.lines) && strings.TrimSpace(e.lines[line]) != "" &&
+		len(e.lines[line])-len(strings.TrimLeft(e.lines[line], " ")) > indent {
+		line++
+	}
+	e.line, e.text = line, text
+}
+
+// itemPrefix returns the indentation and dash before a block list item
+func (e *ConfigEdit) itemPrefix(item *yaml.Node) (string, bool) {
+	if item.Line < 1 || item.Line > len(e.lines) || item.Column-1 > len(e.lines[item.Line-1]) {
+		return "", false
+	}
+	prefix := e.lines[item.Line-1][:item.Column-1]
+	if strings.TrimSpace(prefix) != "-" {
+		return "", false
+	}
+	return prefix, true
+}
+
+// content returns the config file with the text inserted
+func (e *ConfigEdit) content() []byte {
+	lines := make([]string, 0, len(e.lines)+1)
+	lines = append(lines, e.lines[:e.line]...)
+	if e.line > 0 && !strings.HasSuffix(lines[e.line-1], "\n") {
+		lines[e.line-1] += "\n"
+	}
+	lines = append(lines, e.text)
+	lines = append(lines, e.lines[e.line:]...)
+	return []byte(strings.Join(lines, ""))
+}
+
+// validate checks the config file can still be parsed once the text is inserted
+func (e *ConfigEdit) validate() error {
+	if err := yaml.Unmarshal(e.content(), &yaml.Node{}); err != nil {
+		return fmt.Errorf("unable to add link to '%s' without reformatting it: %w", e.Path, err)
+	}
+	return nil
+}
+
+// configEntry returns the key and value of the config list in the root mapping
+func configEntry(root *yaml.Node) (*yaml.Node, *yaml.Node) {
+	for i := 0; i < len(root.Content)-1; i += 2 {
+		if root.Content[i].Value == "config" {
+			return root.Content[i], root.Content[i+1]
+		}
+	}
+	return nil, nil
+}
+
+// lastLine returns the last line containing the node or any of its children
+func lastLine(n *yaml.Node) int {
+	line := n.Line
+	for _, child := range n.Content {
+		if childLine := lastLine(child); childLine > line {
+			line = childLine
+		}
+	}
+	return line
+}
+
+// linkDirective returns a new link directive containing the entry as a list
+// item, prefix is the indentation and dash of the item
+func linkDirective(prefix string, entry string) string {
+	return prefix + "link:\n" + strings.Repeat(" ", len(prefix)+2) + entry
+}
+
+// formatScalar returns the value as a yaml scalar, quoting it if required
+func formatScalar(value string) string {
+	data, _ := yaml.Marshal(value)
+	return strings.TrimSuffix(string(data), "\n")
+}
@@ -0,0 +1,130 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddLinkEntry(t *testing.T) {
+	tests := []struct {
+		name   string
+		group  string
+		config string
+		want   string
+	}{
+		{"link map", "", `# links
+config:
+  - link:
+      ~/.bashrc: bashrc # shell
+      ~/.vimrc:
+        source: vimrc
+        force: true
+
+  # other
+  - shell: echo hi
+`, `# links
+config:
+  - link:
+      ~/.bashrc: bashrc # shell
+      ~/.vimrc:
+        source: vimrc
+        force: true
+      ~/.config/foo.toml: config/foo.toml
+
+  # other
+  - shell: echo hi
+`},
+		{"link list", "", `- link:
+    - ~/.bashrc: bashrc
+`, `- link:
+    - ~/.bashrc: bashrc
+    - ~/.config/foo.toml: config/foo.toml
+`},
+		{"new directive", "", `config:
+    -   shell: |
+          echo hi
+          echo there
+# end`, `config:
+    -   shell: |
+          echo hi
+          echo there
+    -   link:
+          ~/.config/foo.toml: config/foo.toml
+# end`},
+		{"no config", "", `vars:
+  a: b
+`, `vars:
+  a: b
+config:
+  - link:
+      ~/.config/foo.toml: config/foo.toml
+`},
+		{"null config", "", `config:
+vars:
+  a: b
+`, `config:
+  - link:
+      ~/.config/foo.toml: config/foo.toml
+vars:
+  a: b
+`},
+		{"empty file", "", ``, `- link:
+    ~/.config/foo.toml: config/foo.toml
+`},
+		{"group", "work", `- group:
+    home:
+      - link:
+          ~/.bashrc: bashrc
+    work:
+      - shell: echo work
+- shell: echo done
+`, `- group:
+    home:
+      - link:
+          ~/.bashrc: bashrc
+    work:
+      - shell: echo work
+      - link:
+          ~/.config/foo.toml: config/foo.toml
+- shell: echo done
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "dotbot.yaml")
+			if err := os.WriteFile(path, []byte(tt.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			edit, err := AddLinkEntry(path, tt.group, "~/.config/foo.toml", "config/foo.toml")
+			if err != nil {
+				t.Fatalf("AddLinkEntry() error: %v", err)
+			}
+			if err := edit.Save(); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if string(data) != tt.want {
+				t.Errorf("saved config:\n%s\nwant:\n%s", data, tt.want)
+			}
+			if err := edit.Revert(); err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := os.ReadFile(path); string(data) != tt.config {
+				t.Errorf("reverted config:\n%s\nwant:\n%s", data, tt.config)
+			}
+		})
+	}
+
+	t.Run("flow list", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dotbot.yaml")
+		if err := os.WriteFile(path, []byte("config: [{shell: echo hi}]\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := AddLinkEntry(path, "", "~/.config/foo.toml", "config/foo.toml")
+		if err == nil || !strings.Contains(err.Error(), "is not a block list") {
+			t.Errorf("AddLinkEntry() error = %v, want not a block list", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/utils"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// parseChecksums reads a checksum file as written by sha256sum or goreleaser,
+// lines of "<hash>  <name>" where binary mode names are prefixed with '*'
+func parseChecksums(data []byte) map[string]string {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		name := strings.TrimPrefix(strings.Join(fields[1:], " "), "*")
+		checksums[path.Base(name)] = strings.ToLower(fields[0])
+	}
+	return checksums
+}
+
+func fetchBytes(url string) ([]byte, error) {
+	log.Debugln("fetching", url)
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// findChecksum returns the checksum of the named file from a checksum file,
+// files containing only a hash such as <name>.sha256 are also accepted
+func findChecksum(data []byte, name string, url string) (string, error) {
+	if fields := strings.Fields(string(data)); len(fields) == 1 {
+		return strings.ToLower(fields[0]), nil
+	}
+	checksum, ok := parseChecksums(data)[name]
+	if !ok {
+		return "", fmt.Errorf("no checksum for %s in %s", name, url)
+	}
+	return checksum, nil
+}
+
+// verifyChecksum compares the sha256 sum of a file to the expected hex encoded sum
+func verifyChecksum(name string, sum []byte, expected string) error {
+	actual := hex.EncodeToString(sum)
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("checksum mismatch for %s, expected %s but got %s", name, strings.TrimSpace(expected), actual)
+	}
+	log.Debugln("verified checksum", name, actual)
+	return nil
+}
+
+// verifySignature checks the message read from r against the
+// minisign signature at signatureUrl made by publicKey
+func verifySignature(r io.Reader, name string, signatureUrl string, publicKey string) error {
+	key, err := utils.ParseMinisignKey(publicKey)
+	if err != nil {
+		return err
+	}
+	data, err := fetchBytes(signatureUrl)
+	if err != nil {
+		return err
+	}
+	sig, err := utils.ParseMinisignSignature(data)
+	if err != nil {
+		return err
+	}
+	err = key.Verify(r, sig)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature of %s: %w", name, err)
+	}
+	log.Debugln("verified signature", name, sig.TrustedComment)
+	return nil
+}
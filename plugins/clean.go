@@ -62,6 +62,7 @@ func (b CleanBase) RunAll() error {
 		}
 		if err != nil {
 			fmt.Println("error:", err)
+			taskFailed()
 		}
 		if cleaned_ == true {
 			cleaned = true
@@ -96,20 +97,27 @@ func (c CleanConfig) Run() (bool, error) {
 		if err != nil {
 			return err
 		}
+		// relative links are resolved from the directory containing the link
+		if !filepath.IsAbs(dest) {
+			dest = filepath.Join(filepath.Dir(path), dest)
+		}
 		// check link is to dotfiles directory
 		rel, err := filepath.Rel(store.BaseDir(), dest)
 		if !c.Force && (err != nil || strings.HasPrefix(rel, "..")) {
 			return nil
 		}
 		pathStat, _ := entry.Info()
-		// check dead link
-		if stat, err := os.Stat(dest); err != nil {
+		// check dead link or link whose source is no longer matched by its glob
+		if stat, err := os.Stat(dest); err != nil || isStaleGlobLink(path, dest) {
 			cleaned = true
 			if !store.DryRun {
 				err := os.Remove(path)
 				if err != nil {
 					return err
 				}
+				if absPath, err := filepath.Abs(path); err == nil {
+					store.Manifest.Unset("link_glob:" + absPath)
+				}
 			}
 			cleanLogger.TagC(emerald.Red, "deleted").Path(
 				emerald.HighlightPathStat(utils.ShrinkUser(path), pathStat),
@@ -118,6 +126,11 @@ func (c CleanConfig) Run() (bool, error) {
 		}
 		return nil
 	})
+	if cleaned && !store.DryRun {
+		if err := store.Save(); err != nil {
+			return cleaned, err
+		}
+	}
 	if os.IsNotExist(err) {
 		return cleaned, nil
 	}
@@ -1,6 +1,9 @@
 package plugins
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"github.com/creasty/defaults"
 	"github.com/jcwillox/dotbot/log"
@@ -17,6 +20,7 @@ import (
 	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -26,13 +30,17 @@ var downloadLogger = log.NewBasicLogger("DOWNLOAD")
 
 type DownloadBase []*DownloadConfig
 type DownloadConfig struct {
-	Name    string
-	Url     string
-	Path    string `yaml:",omitempty"`
-	Mkdirs  bool   `default:"true"`
-	Force   bool
-	Mode    utils.WeakFileMode `default:"438"`
-	Extract ExtractItems
+	Name        string
+	Url         string
+	Path        string `yaml:",omitempty"`
+	Mkdirs      bool   `default:"true"`
+	Force       bool
+	Mode        utils.WeakFileMode `default:"438"`
+	Sha256      string             `yaml:",omitempty"`
+	ChecksumUrl string             `yaml:"checksum_url,omitempty"`
+	Signature   string             `yaml:",omitempty"`
+	PublicKey   string             `yaml:"public_key,omitempty"`
+	Extract     ExtractItems
 }
 
 func (b *DownloadBase) UnmarshalYAML(n *yaml.Node) error {
@@ -60,6 +68,7 @@ func (b DownloadBase) Enabled() bool {
 }
 
 func (b DownloadBase) RunAll() error {
+	hasError := false
 	for _, config := range b {
 		err := config.Run()
 		if sudo.IsPermission(err) && sudo.WouldSudo() {
@@ -73,28 +82,27 @@ func (b DownloadBase) RunAll() error {
 			err = sudo.Config("download", &config)
 		}
 		if err != nil {
-			fmt.Println("ERROR:", err)
-			os.Exit(1)
+			reportError(err)
+			hasError = true
 		}
 	}
+	if hasError {
+		return errors.New("failed to download some files")
+	}
 	return nil
 }
 
 func (c *DownloadConfig) Run() error {
 	var f *os.File
 	// allow templating
-	err := template.RenderField(&c.Url, &c.Path)
+	err := template.RenderField(&c.Url, &c.Path, &c.Sha256, &c.ChecksumUrl, &c.Signature, &c.PublicKey)
 	if err != nil {
 		return err
 	}
-	// special handling of urls starting with '/'
-	if strings.HasPrefix(c.Url, "/") {
-		if url, present := store.GetVar("Url"); present {
-			c.Url = url.(string) + c.Url
-		}
-	} else if !strings.HasPrefix(c.Url, "http") {
-		c.Url = "http://" + c.Url
+	if c.Signature != "" && c.PublicKey == "" {
+		return errors.New("signature requires public_key")
 	}
+	c.Url = resolveUrl(c.Url)
 
 	// get actual download length and url
 	head, err := http.Head(c.Url)
@@ -112,10 +120,39 @@ func (c *DownloadConfig) Run() error {
 	}
 	log.Debugf("filename: '%s'\n", name)
 
+	// resolve the expected checksum before anything is downloaded, when
+	// there is a signature it is for the checksum file rather than the download
+	// so the checksum file is always fetched to be verified
+	checksum := c.Sha256
+	if c.ChecksumUrl != "" && (checksum == "" || c.Signature != "") {
+		checksumUrl := resolveUrl(c.ChecksumUrl)
+		data, err := fetchBytes(checksumUrl)
+		if err != nil {
+			return err
+		}
+		if c.Signature != "" {
+			err := verifySignature(bytes.NewReader(data), path.Base(checksumUrl), resolveUrl(c.Signature), c.PublicKey)
+			if err != nil {
+				return err
+			}
+		}
+		signed, err := findChecksum(data, name, checksumUrl)
+		if err != nil {
+			return err
+		}
+		if checksum != "" && !strings.EqualFold(strings.TrimSpace(checksum), signed) {
+			return fmt.Errorf("sha256 of %s does not match the signed checksum file %s", name, checksumUrl)
+		}
+		checksum = signed
+	}
+
 	if store.DryRun {
 		return nil
 	}
 
+	// destination the download is moved to once it has been verified
+	dest := ""
+
 	if c.Path == "" {
 		// use proper download file does not exist
 		path := filepath.Join(os.TempDir(), name)
@@ -125,8 +162,8 @@ func (c *DownloadConfig) Run() error {
 				return err
 			}
 		} else {
-			ext := filepath.Ext(name)
-			f, err = os.CreateTemp("", "dotbot-*"+ext)
+			// keep the whole name so extensions such as .tar.gz are preserved
+			f, err = os.CreateTemp("", "dotbot-*-"+name)
 			if err != nil {
 				return err
 			}
@@ -160,19 +197,24 @@ func (c *DownloadConfig) Run() error {
 				return err
 			}
 		}
-		f, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY, os.FileMode(c.Mode))
+		dest = path
+		f, err = os.OpenFile(filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".download"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(c.Mode))
 		if err != nil {
 			return err
 		}
+		defer os.Remove(f.Name())
 	}
 	log.Debugln("destination:", f.Name())
 
 	// download file
 	resp, err := http.Get(c.Url)
 	if err != nil {
+		f.Close()
 		return err
 	}
 	defer resp.Body.Close()
+	hash := sha256.New()
+	w := io.MultiWriter(f, hash)
 
 	if c.Name != "" {
 		name = c.Name
@@ -191,15 +233,17 @@ func (c *DownloadConfig) Run() error {
 		proxyReader := bar.ProxyReader(resp.Body)
 		defer proxyReader.Close()
 
-		_, err = io.Copy(f, proxyReader)
+		_, err = io.Copy(w, proxyReader)
 		if err != nil {
+			f.Close()
 			return err
 		}
 
 		p.Wait()
 	} else {
-		_, err = io.Copy(f, resp.Body)
+		_, err = io.Copy(w, resp.Body)
 		if err != nil {
+			f.Close()
 			return err
 		}
 	}
@@ -207,15 +251,54 @@ func (c *DownloadConfig) Run() error {
 	if err != nil {
 		return err
 	}
+	if checksum != "" {
+		err = verifyChecksum(name, hash.Sum(nil), checksum)
+	}
+	if err == nil && c.Signature != "" && c.ChecksumUrl == "" {
+		err = c.verifyFile(f.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	archive := f.Name()
+	if dest != "" {
+		err := os.Rename(f.Name(), dest)
+		if err != nil {
+			return err
+		}
+		archive = dest
+	}
 	if c.Extract != nil && len(c.Extract) > 0 {
 		return ExtractConfig{
-			Archive: f.Name(),
+			Archive: archive,
 			Items:   c.Extract,
 		}.Run()
 	}
 	return nil
 }
 
+func (c *DownloadConfig) verifyFile(path string, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return verifySignature(f, name, resolveUrl(c.Signature), c.PublicKey)
+}
+
+// resolveUrl prefixes urls starting with '/' with the url of the current install
+func resolveUrl(url string) string {
+	if strings.HasPrefix(url, "/") {
+		if base, present := store.GetVar("Url"); present {
+			return base.(string) + url
+		}
+	} else if !strings.HasPrefix(url, "http") {
+		return "http://" + url
+	}
+	return url
+}
+
 func AddProgressBar(p *mpb.Progress, total int64, desc string) *mpb.Bar {
 	if total < 0 {
 		return p.Add(total,
@@ -0,0 +1,130 @@
+package plugins
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/jcwillox/emerald"
+	"golang.org/x/crypto/blake2b"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newMinisignKey returns a minisign public key and a function signing with it
+func newMinisignKey(t *testing.T) (string, func(message []byte) []byte) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	key := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+	return key, func(message []byte) []byte {
+		hash := blake2b.Sum512(message)
+		sig := ed25519.Sign(priv, hash[:])
+		comment := "file:checksums.txt"
+		global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+		return []byte(fmt.Sprintf(
+			"untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+			base64.StdEncoding.EncodeToString(append(append([]byte("ED"), id...), sig...)),
+			comment, base64.StdEncoding.EncodeToString(global),
+		))
+	}
+}
+
+func TestDownloadSignedChecksums(t *testing.T) {
+	emerald.SetColorState(false)
+	content := []byte("#!/bin/sh\necho tool\n")
+	sum := sha256.Sum256(content)
+	checksums := []byte(hex.EncodeToString(sum[:]) + "  tool.sh\n" + strings.Repeat("0", 64) + "  other.sh\n")
+	key, sign := newMinisignKey(t)
+	_, signOther := newMinisignKey(t)
+
+	files := map[string][]byte{
+		"/tool.sh":                   content,
+		"/checksums.txt":             checksums,
+		"/checksums.txt.minisig":     sign(checksums),
+		"/other.minisig":             signOther(checksums),
+		"/tampered.txt":              []byte(strings.Repeat("f", 64) + "  tool.sh\n"),
+		"/tampered.txt.minisig":      sign(checksums),
+		"/bad-checksums.txt":         []byte(strings.Repeat("f", 64) + "  tool.sh\n"),
+		"/bad-checksums.txt.minisig": sign([]byte(strings.Repeat("f", 64) + "  tool.sh\n")),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  DownloadConfig
+		wantErr string
+	}{
+		{"signed checksums", DownloadConfig{
+			ChecksumUrl: "/checksums.txt", Signature: "/checksums.txt.minisig",
+		}, ""},
+		{"sha256 with signed checksums", DownloadConfig{
+			Sha256: hex.EncodeToString(sum[:]), ChecksumUrl: "/checksums.txt", Signature: "/checksums.txt.minisig",
+		}, ""},
+		{"sha256 differs from signed checksums", DownloadConfig{
+			Sha256: strings.Repeat("0", 64), ChecksumUrl: "/checksums.txt", Signature: "/checksums.txt.minisig",
+		}, "does not match the signed checksum file"},
+		{"wrong key", DownloadConfig{
+			ChecksumUrl: "/checksums.txt", Signature: "/other.minisig",
+		}, "signature was made by key"},
+		{"tampered checksums", DownloadConfig{
+			ChecksumUrl: "/tampered.txt", Signature: "/tampered.txt.minisig",
+		}, "invalid signature"},
+		{"signed checksum mismatch", DownloadConfig{
+			ChecksumUrl: "/bad-checksums.txt", Signature: "/bad-checksums.txt.minisig",
+		}, "checksum mismatch"},
+		{"missing signature", DownloadConfig{
+			ChecksumUrl: "/checksums.txt", Signature: "/missing.minisig",
+		}, "404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := filepath.Join(t.TempDir(), "tool.sh")
+			config := tt.config
+			config.Url = server.URL + "/tool.sh"
+			config.Path = dest
+			config.Mode = 0644
+			config.ChecksumUrl = server.URL + config.ChecksumUrl
+			config.Signature = server.URL + config.Signature
+			config.PublicKey = key
+
+			err := config.Run()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				if _, err := os.Stat(dest); !os.IsNotExist(err) {
+					t.Errorf("download was moved into place after failed verification")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+			data, err := os.ReadFile(dest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(content) {
+				t.Errorf("downloaded %q, want %q", data, content)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/bmatcuk/doublestar/v4"
+	"github.com/jcwillox/dotbot/backup"
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/store"
 	"github.com/jcwillox/dotbot/template"
@@ -87,7 +88,7 @@ func (b ExtractBase) RunAll() error {
 	for _, config := range b {
 		err := config.Run()
 		if err != nil {
-			fmt.Println("ERROR:", err)
+			reportError(err)
 		}
 	}
 	return nil
@@ -116,9 +117,15 @@ func (c ExtractConfig) Run() error {
 			dest := utils.ExpandUser(item.Path)
 			dest, _, _ = strings.Cut(dest, "/#/")
 			if item.Replace {
-				err := os.RemoveAll(dest)
-				if err != nil {
-					return err
+				if _, err := os.Lstat(dest); err == nil {
+					backupPath, err := backup.Save(dest, "extract")
+					if err != nil {
+						return err
+					}
+					extractLogger.TagC(emerald.Red, "backed up").Path(
+						emerald.HighlightPath(item.Path, os.ModeDir),
+						emerald.HighlightPath(utils.ShrinkUser(backupPath), os.ModeDir),
+					)
 				}
 			}
 			err := os.MkdirAll(dest, os.ModePerm)
@@ -0,0 +1,129 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/template"
+	"github.com/jcwillox/dotbot/yamltools"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type ForEachBase []*ForEachConfig
+type ForEachConfig struct {
+	// Items is a literal list or map, the name of a template variable,
+	// or a template that renders to a yaml list, map or lines of text
+	Items interface{}
+	Do    PluginList
+}
+
+func (b *ForEachBase) UnmarshalYAML(n *yaml.Node) error {
+	n = yamltools.EnsureList(n)
+	type ForEachBaseT ForEachBase
+	return n.Decode((*ForEachBaseT)(b))
+}
+
+func (c *ForEachConfig) UnmarshalYAML(n *yaml.Node) error {
+	type ForEachConfigT ForEachConfig
+	return n.Decode((*ForEachConfigT)(c))
+}
+
+func (b ForEachBase) Enabled() bool {
+	return true
+}
+
+func (b ForEachBase) RunAll() error {
+	hasError := false
+	for _, config := range b {
+		err := config.Run()
+		if err != nil {
+			reportError(err)
+			hasError = true
+		}
+	}
+	if hasError {
+		return errors.New("failed to run some loops")
+	}
+	return nil
+}
+
+func (c ForEachConfig) Run() error {
+	items, err := c.resolveItems()
+	if err != nil {
+		return err
+	}
+	if items == nil {
+		return nil
+	}
+	hasError := false
+	v := reflect.ValueOf(items)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if c.runItem(i, i, v.Index(i).Interface()) != nil {
+				hasError = true
+			}
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for i, key := range keys {
+			if c.runItem(i, key.Interface(), v.MapIndex(key).Interface()) != nil {
+				hasError = true
+			}
+		}
+	default:
+		return fmt.Errorf("for_each items must be a list or map, got %T", items)
+	}
+	if hasError {
+		return errors.New("failed to run some for_each items")
+	}
+	return nil
+}
+
+func (c ForEachConfig) runItem(index int, key interface{}, item interface{}) error {
+	restore := store.VarsClosure(map[string]interface{}{
+		"Item":  item,
+		"Key":   key,
+		"Index": index,
+	})
+	defer restore()
+	return runNested(c.Do)
+}
+
+func (c ForEachConfig) resolveItems() (interface{}, error) {
+	s, ok := c.Items.(string)
+	if !ok {
+		return c.Items, nil
+	}
+	if !template.HasTemplate(s) {
+		if value, present := store.GetVar(s); present {
+			return value, nil
+		}
+		return nil, errors.New("for_each variable '" + s + "' is not defined")
+	}
+	result, err := template.Parse(s).Render()
+	if err != nil {
+		return nil, err
+	}
+	// prefer structured output, but fallback to treating each line as an item
+	var items interface{}
+	if err := yaml.Unmarshal([]byte(result), &items); err == nil {
+		switch items.(type) {
+		case []interface{}, map[string]interface{}:
+			return items, nil
+		}
+	}
+	lines := make([]interface{}, 0)
+	for _, line := range strings.Split(result, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,89 @@
+package plugins
+
+import (
+	"github.com/jcwillox/dotbot/store"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runForEach runs a for_each directive with the vars set, returning the
+// lines written to the file in the Out var and the error of the directive
+func runForEach(t *testing.T, config string, vars map[string]interface{}) ([]string, error) {
+	out := filepath.Join(t.TempDir(), "out")
+	restore := store.VarsClosure(map[string]interface{}{"Out": out})
+	defer restore()
+	defer store.VarsClosure(vars)()
+
+	var b ForEachBase
+	if err := yaml.Unmarshal([]byte(config), &b); err != nil {
+		t.Fatal(err)
+	}
+	err := b.RunAll()
+	data, readErr := os.ReadFile(out)
+	if readErr != nil && !os.IsNotExist(readErr) {
+		t.Fatal(readErr)
+	}
+	return strings.Fields(strings.ReplaceAll(string(data), "\n", ",")), err
+}
+
+const forEachDo = `
+  do:
+    - shell: echo "{{ .Index }}:{{ .Key }}:{{ .Item }}" >> "{{ .Out }}"
+`
+
+func TestForEach(t *testing.T) {
+	tests := []struct {
+		name  string
+		items string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{"list", "items: [a, b]", nil, "0:0:a,1:1:b,"},
+		{"map", "items: {y: 2, x: 1}", nil, "0:x:1,1:y:2,"},
+		{"variable", "items: names", map[string]interface{}{"names": []interface{}{"c", "d"}}, "0:0:c,1:1:d,"},
+		{"template list", `items: '{{ .Names }}'`, map[string]interface{}{"Names": "[e, f]"}, "0:0:e,1:1:f,"},
+		{"template lines", `items: '{{ .Names }}'`, map[string]interface{}{"Names": "g\n\nh i\n"}, "0:0:g,1:1:h i,"},
+		{"empty", "items: []", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runForEach(t, "- "+tt.items+forEachDo, tt.vars)
+			if err != nil {
+				t.Fatalf("RunAll() error: %v", err)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("ran %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestForEachErrors(t *testing.T) {
+	if _, err := runForEach(t, "- items: missing"+forEachDo, nil); err == nil {
+		t.Errorf("expected an error for an undefined variable")
+	}
+	if _, err := runForEach(t, "- items: '{{ .Bad }}'"+forEachDo, map[string]interface{}{"Bad": "{a: [}"}); err != nil {
+		t.Errorf("invalid yaml should be treated as lines: %v", err)
+	}
+
+	// failures of items are propagated but the remaining items still run
+	failed := FailedTasks()
+	got, err := runForEach(t, `
+- items: [a, b, c]
+  do:
+    - shell: test "{{ .Item }}" != b && echo "{{ .Item }}" >> "{{ .Out }}"
+`, nil)
+	if err == nil {
+		t.Errorf("expected the failed item to be returned as an error")
+	}
+	if strings.Join(got, " ") != "a,c," {
+		t.Errorf("ran %q, want the remaining items to run", strings.Join(got, " "))
+	}
+	// the failed shell command and the loop itself
+	if FailedTasks()-failed != 2 {
+		t.Errorf("counted %d failed tasks, want 2", FailedTasks()-failed)
+	}
+}
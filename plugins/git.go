@@ -61,7 +61,7 @@ func (b GitBase) RunAll() error {
 	for _, config := range b {
 		err := config.Run()
 		if err != nil {
-			fmt.Println("ERROR:", err)
+			reportError(err)
 		}
 	}
 	return nil
@@ -0,0 +1,599 @@
+package plugins
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/facts"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/utils/sudo"
+	"github.com/jcwillox/dotbot/yamltools"
+	"github.com/mholt/archiver/v3"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type GithubReleaseBase []*GithubReleaseConfig
+type GithubReleaseConfig struct {
+	Repo      string               `yaml:",omitempty"`
+	Name      string               `yaml:",omitempty"`
+	Api       string               `yaml:",omitempty"`
+	Asset     string               `yaml:",omitempty"`
+	Exclude   []string             `yaml:",omitempty"`
+	Os        []string             `yaml:",omitempty"`
+	Arch      []string             `yaml:",omitempty"`
+	Libc      []string             `yaml:",omitempty"`
+	Binaries  []string             `yaml:",omitempty"`
+	PublicKey string               `yaml:"public_key,omitempty"`
+	Creates   string               `yaml:",omitempty"`
+	Version   GithubReleaseVersion `yaml:",omitempty"`
+	Then      PluginList           `yaml:",omitempty"`
+}
+
+type GithubReleaseVersion struct {
+	Current           *VersionProbe `yaml:",omitempty"`
+	VersionConstraint `yaml:",inline"`
+}
+
+type githubRelease struct {
+	TagName    string        `json:"tag_name"`
+	Prerelease bool          `json:"prerelease"`
+	Assets     []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	Url  string `json:"browser_download_url"`
+}
+
+func (b *GithubReleaseBase) UnmarshalYAML(n *yaml.Node) error {
+	n = yamltools.EnsureList(n)
+	type GithubReleaseBaseT GithubReleaseBase
+	return n.Decode((*GithubReleaseBaseT)(b))
+}
+
+func (c *GithubReleaseConfig) UnmarshalYAML(n *yaml.Node) error {
+	line := n.Line
+	n = yamltools.ScalarToMap(n)
+	if yamltools.IsScalarMap(n) {
+		n = yamltools.MapSplitKeyVal(n, "repo", "name")
+	} else {
+		n = yamltools.MapKeyIntoValueMap(n, "repo")
+	}
+	type GithubReleaseConfigT GithubReleaseConfig
+	err := n.Decode((*GithubReleaseConfigT)(c))
+	if err != nil {
+		return err
+	}
+	if owner, repo, _ := strings.Cut(c.Repo, "/"); owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return fmt.Errorf("line %d: expected repository as owner/repo but got '%s'", line, c.Repo)
+	}
+	if err := c.Version.validate(); err != nil {
+		return fmt.Errorf("line %d: %w", line, err)
+	}
+	return nil
+}
+
+func (c *GithubReleaseConfig) MarshalYAML() (interface{}, error) {
+	repo := c.Repo
+	c.Repo = ""
+	type GithubReleaseConfigT GithubReleaseConfig
+	return map[string]*GithubReleaseConfigT{repo: (*GithubReleaseConfigT)(c)}, nil
+}
+
+func (b GithubReleaseBase) Enabled() bool {
+	return true
+}
+
+func (b GithubReleaseBase) RunAll() error {
+	hasError := false
+	for _, config := range b {
+		err := config.Run()
+		if err != nil {
+			reportError(err)
+			hasError = true
+		}
+	}
+	if hasError {
+		return errors.New("failed to install some releases")
+	}
+	return nil
+}
+
+func (c GithubReleaseConfig) Run() error {
+	release, newer, err := c.resolveRelease()
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.String(), err)
+	}
+	url := "https://github.com/" + c.Repo
+	err = InstallConfig{
+		Name:    c.String(),
+		Url:     url,
+		Version: InstallVersion{Url: url, Current: c.Version.Current},
+		Creates: c.Creates,
+	}.install(release.version(), func() error {
+		return c.installAsset(release.Assets)
+	})
+	if newer != "" {
+		logHeld(c.String(), newer, c.Version.VersionConstraint)
+	}
+	return err
+}
+
+// resolveRelease returns the release to install, and a newer version
+// if one exists that is excluded by the constraint
+func (c GithubReleaseConfig) resolveRelease() (*githubRelease, string, error) {
+	if c.Version.Pin == "" && !c.Version.listed() {
+		release := &githubRelease{}
+		err := getGithubJSON(githubApi(c.Api)+"/repos/"+c.Repo+"/releases/latest", release)
+		if err != nil {
+			return nil, "", err
+		}
+		if release.version() == "" {
+			return nil, "", errors.New("latest release has no tag")
+		}
+		return release, "", nil
+	}
+	releases, err := listGithubReleases(githubApi(c.Api), c.Repo, c.Version.VersionConstraint)
+	if err != nil {
+		return nil, "", err
+	}
+	versions := make([]string, 0, len(releases))
+	for _, release := range releases {
+		if !release.Prerelease || c.Version.Prerelease {
+			versions = append(versions, release.version())
+		}
+	}
+	version, newer := c.Version.Pin, c.Version.newerThanPin(versions)
+	if version == "" {
+		version, newer, err = c.Version.Select(versions)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	for i := range releases {
+		if releases[i].version() == version {
+			return &releases[i], newer, nil
+		}
+	}
+	return nil, "", fmt.Errorf("no release of %s has version %s", c.Repo, version)
+}
+
+func (c GithubReleaseConfig) String() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return path.Base(c.Repo)
+}
+
+// githubApi returns the base url of the GitHub api, which can be
+// overridden to use GitHub Enterprise or a mirror
+func githubApi(override string) string {
+	if override != "" {
+		return strings.TrimRight(override, "/")
+	}
+	if api := os.Getenv("DOTBOT_GITHUB_API"); api != "" {
+		return strings.TrimRight(api, "/")
+	}
+	return "https://api.github.com"
+}
+
+// listGithubReleases returns the releases of a repository from newest to oldest,
+// further pages are only fetched until a release matches the version
+func listGithubReleases(api string, repo string, version VersionConstraint) ([]githubRelease, error) {
+	releases := make([]githubRelease, 0, 100)
+	url := api + "/repos/" + repo + "/releases?per_page=100"
+	for url != "" {
+		page := make([]githubRelease, 0, 100)
+		next, err := fetchGithubJSON(url, &page)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, page...)
+		for _, release := range page {
+			if (!release.Prerelease || version.Prerelease) && version.matches(release.version()) {
+				return releases, nil
+			}
+		}
+		url = next
+	}
+	return releases, nil
+}
+
+// nextLink returns the url of the next page from a Link header
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		url, params, found := strings.Cut(link, ";")
+		if found && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(url), "<>")
+		}
+	}
+	return ""
+}
+
+func (r githubRelease) version() string {
+	return strings.TrimPrefix(r.TagName, "v")
+}
+
+func getGithubJSON(url string, v interface{}) error {
+	_, err := fetchGithubJSON(url, v)
+	return err
+}
+
+// fetchGithubJSON is like getGithubJSON but also returns the url of the next page
+func fetchGithubJSON(url string, v interface{}) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	// authenticate to avoid the low rate limit of anonymous requests
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	log.Debugln("fetching", url)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	return nextLink(resp.Header.Get("Link")), json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c GithubReleaseConfig) installAsset(assets []githubAsset) error {
+	asset, err := c.selectAsset(assets)
+	if err != nil {
+		return err
+	}
+	log.Debugln("selected asset", asset.Name)
+	if store.DryRun {
+		return nil
+	}
+
+	checksumUrl := checksumAsset(assets, asset.Name)
+	signatureUrl := ""
+	if c.PublicKey != "" {
+		signatureUrl, err = signatureAsset(assets, asset.Name, checksumUrl)
+		if err != nil {
+			return err
+		}
+	}
+	local := utils.GetLocal()
+	useSudo := sudo.WouldSudo() && !canCreate(local)
+	if !isArchive(asset.Name) {
+		// asset is the binary itself
+		name := c.binaries()[0]
+		if facts.String("os") == "windows" {
+			name += ".exe"
+		}
+		download := &DownloadConfig{
+			Url:         asset.Url,
+			Path:        local + "/bin/" + name,
+			Mkdirs:      true,
+			Force:       true,
+			Mode:        0755,
+			ChecksumUrl: checksumUrl,
+			Signature:   signatureUrl,
+			PublicKey:   c.PublicKey,
+		}
+		if useSudo {
+			err = sudo.Config("download", download)
+		} else {
+			err = download.Run()
+		}
+		if err != nil {
+			return err
+		}
+		return c.Then.RunAll()
+	}
+
+	download := &DownloadConfig{
+		Url:         asset.Url,
+		Mode:        0644,
+		ChecksumUrl: checksumUrl,
+		Signature:   signatureUrl,
+		PublicKey:   c.PublicKey,
+	}
+	err = download.Run()
+	if err != nil {
+		return err
+	}
+	archive, _ := store.GetVar("Path")
+	items, err := c.placeItems(archive.(string), local)
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("found nothing to install in %s", asset.Name)
+	}
+	extract := &ExtractConfig{Archive: archive.(string), Items: items}
+	if useSudo {
+		err = sudo.Config("extract", extract)
+	} else {
+		err = extract.Run()
+	}
+	if err != nil {
+		return err
+	}
+	return c.Then.RunAll()
+}
+
+// checksumAsset returns the url of the sha256 checksums published
+// alongside an asset, or an empty string if there are none
+func checksumAsset(assets []githubAsset, name string) string {
+	for _, asset := range assets {
+		if strings.EqualFold(asset.Name, name+".sha256") || strings.EqualFold(asset.Name, name+".sha256sum") {
+			return asset.Url
+		}
+	}
+	for _, asset := range assets {
+		lower := strings.ToLower(asset.Name)
+		if lower == "sha256sums" || lower == "sha256sums.txt" ||
+			(strings.Contains(lower, "checksums") && (path.Ext(lower) == ".txt" || path.Ext(lower) == "") &&
+				!strings.Contains(lower, "sha1") && !strings.Contains(lower, "sha512") && !strings.Contains(lower, "md5")) {
+			return asset.Url
+		}
+	}
+	return ""
+}
+
+// signatureAsset returns the url of the minisign signature of the checksum
+// file if there is one, as that is what download verifies, otherwise the
+// signature of the asset itself
+func signatureAsset(assets []githubAsset, name string, checksumUrl string) (string, error) {
+	if checksumUrl != "" {
+		name = path.Base(checksumUrl)
+	}
+	for _, asset := range assets {
+		if asset.Name == name+".minisig" {
+			return asset.Url, nil
+		}
+	}
+	return "", fmt.Errorf("release has no signature %s.minisig", name)
+}
+
+func (c GithubReleaseConfig) binaries() []string {
+	if len(c.Binaries) > 0 {
+		return c.Binaries
+	}
+	return []string{path.Base(c.Repo)}
+}
+
+var manPageRegex = regexp.MustCompile(`\.([1-9])(\.gz)?$`)
+
+// placeItems lists the archive to decide where each binary, man page
+// and shell completion belongs under the local directory
+func (c GithubReleaseConfig) placeItems(archive string, local string) (ExtractItems, error) {
+	f, err := archiver.ByExtension(archive)
+	if err != nil {
+		return nil, err
+	}
+	w, ok := f.(archiver.Walker)
+	if !ok {
+		return nil, fmt.Errorf("cannot list files in %s", filepath.Base(archive))
+	}
+	binaries := c.binaries()
+	items := make(ExtractItems, 0, 8)
+	err = w.Walk(archive, func(f archiver.File) error {
+		hName := getHeaderName(f)
+		if f.IsDir() || hName == "" {
+			return nil
+		}
+		base := path.Base(hName)
+		name := strings.TrimSuffix(base, path.Ext(base))
+		var dest string
+		switch {
+		case utils.ArrContains(binaries, base) || (path.Ext(base) == ".exe" && utils.ArrContains(binaries, name)):
+			dest = local + "/bin"
+		case len(c.Binaries) == 0 && path.Ext(base) == "" && f.Mode()&0111 != 0:
+			// assume any executable is wanted when binaries are not given
+			dest = local + "/bin"
+		case manPageRegex.MatchString(base):
+			dest = local + "/share/man/man" + manPageRegex.FindStringSubmatch(base)[1]
+		case strings.HasSuffix(base, ".zsh"):
+			dest = local + "/share/zsh/site-functions/#/_" + name
+		case strings.HasPrefix(base, "_") && path.Ext(base) == "":
+			dest = local + "/share/zsh/site-functions"
+		case strings.HasSuffix(base, ".bash"):
+			dest = local + "/share/bash-completion/completions/#/" + name
+		case strings.HasSuffix(base, ".fish"):
+			dest = local + "/share/fish/vendor_completions.d"
+		default:
+			return nil
+		}
+		items = append(items, &ExtractItem{Source: hName, Path: dest})
+		return nil
+	})
+	return items, err
+}
+
+// canCreate reports whether files can be created in dir or
+// the closest parent directory that exists
+func canCreate(dir string) bool {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+	f, err := os.CreateTemp(dir, ".dotbot-*")
+	if err != nil {
+		return false
+	}
+	f.Close()
+	_ = os.Remove(f.Name())
+	return true
+}
+
+var (
+	archiveExts = []string{".tar.gz", ".tgz", ".tar.xz", ".txz", ".tar.bz2", ".tbz2", ".tar.zst", ".zip"}
+	// ignoredExts are never selected as they are not installable by copying
+	ignoredExts = []string{
+		".sha256", ".sha256sum", ".sha512", ".md5", ".sig", ".asc", ".pem", ".sbom", ".json", ".txt",
+		".deb", ".rpm", ".apk", ".msi", ".pkg", ".dmg", ".gz", ".xz", ".bz2", ".zst",
+	}
+	osPatterns = map[string][]string{
+		"linux":   {"linux"},
+		"darwin":  {"darwin", "macos", "osx", "apple", "mac"},
+		"windows": {"windows", "win", "win64", "win32"},
+		"freebsd": {"freebsd"},
+	}
+	archPatterns = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x64", "64bit"},
+		"386":   {"386", "i386", "i686", "x86", "32bit"},
+		"arm64": {"arm64", "aarch64", "armv8"},
+		"arm":   {"arm", "armv7", "armv6", "armhf"},
+	}
+	libcPatterns = map[string][]string{
+		"gnu":  {"gnu", "glibc"},
+		"musl": {"musl"},
+	}
+	// archAliases makes x86_64 a single token so it is not matched as x86
+	archAliases = strings.NewReplacer("x86_64", "amd64", "x86-64", "amd64")
+)
+
+func isArchive(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range archiveExts {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny reports whether any of the patterns appear in name as a
+// separate word, so that arm does not match arm64
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = archAliases.Replace(strings.ToLower(pattern))
+		re := regexp.MustCompile(`(^|[^a-z0-9])` + regexp.QuoteMeta(pattern) + `([^a-z0-9]|$)`)
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAsset picks the asset matching the asset pattern if given, otherwise
+// the asset that best matches the operating system, architecture and libc
+func (c GithubReleaseConfig) selectAsset(assets []githubAsset) (githubAsset, error) {
+	names := make([]string, 0, len(assets))
+	for _, asset := range assets {
+		names = append(names, asset.Name)
+	}
+	if c.Asset != "" {
+		for _, asset := range assets {
+			if matched, _ := path.Match(c.Asset, asset.Name); matched {
+				return asset, nil
+			}
+		}
+		return githubAsset{}, fmt.Errorf("no release asset of %s matches '%s', found: %s", c.Repo, c.Asset, strings.Join(names, ", "))
+	}
+	best, bestScore := -1, -1
+	for i, asset := range assets {
+		score := c.scoreAsset(asset.Name)
+		log.Debugf("[asset] %s score=%d\n", asset.Name, score)
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return githubAsset{}, fmt.Errorf("no release asset of %s matches %s/%s, found: %s", c.Repo, facts.String("os"), facts.String("arch"), strings.Join(names, ", "))
+	}
+	return assets[best], nil
+}
+
+// scoreAsset ranks how well an asset matches this machine, negative
+// scores are for assets which cannot be used, the os, arch and libc
+// facts are used so that they can be overridden
+func (c GithubReleaseConfig) scoreAsset(name string) int {
+	goos, goarch := facts.String("os"), facts.String("arch")
+	for _, pattern := range c.Exclude {
+		if matched, _ := path.Match(pattern, name); matched {
+			return -1
+		}
+	}
+	name = archAliases.Replace(strings.ToLower(name))
+	score := 0
+	if isArchive(name) {
+		// archives usually include man pages and completions
+		score += 1
+	} else {
+		for _, ext := range ignoredExts {
+			if strings.HasSuffix(name, ext) {
+				return -1
+			}
+		}
+		if strings.Contains(name, "checksums") {
+			return -1
+		}
+		if (goos == "windows") != strings.HasSuffix(name, ".exe") {
+			return -1
+		}
+	}
+
+	// operating system must always match
+	osNames := c.Os
+	if len(osNames) == 0 {
+		osNames = osPatterns[goos]
+		if osNames == nil {
+			osNames = []string{goos}
+		}
+	}
+	if !matchesAny(name, osNames) {
+		return -1
+	}
+
+	archNames := c.Arch
+	if len(archNames) == 0 {
+		archNames = archPatterns[goarch]
+		if archNames == nil {
+			archNames = []string{goarch}
+		}
+	}
+	if matchesAny(name, archNames) {
+		score += 4
+	} else {
+		for arch, patterns := range archPatterns {
+			if arch != goarch && matchesAny(name, patterns) {
+				return -1
+			}
+		}
+		// no architecture such as a universal binary
+		if strings.Contains(name, "universal") {
+			score += 2
+		}
+	}
+
+	if goos == "linux" {
+		libc := facts.String("libc")
+		libcNames := c.Libc
+		if len(libcNames) == 0 {
+			libcNames = libcPatterns[libc]
+		}
+		if matchesAny(name, libcNames) {
+			score += 2
+		} else if matchesAny(name, libcPatterns["musl"]) {
+			// static musl binaries also run on glibc systems
+			score += 1
+		} else if libc == "musl" && matchesAny(name, libcPatterns["gnu"]) {
+			return -1
+		}
+	}
+	return score
+}
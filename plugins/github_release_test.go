@@ -0,0 +1,254 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jcwillox/dotbot/facts"
+	"github.com/mholt/archiver/v3"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// withFacts overrides facts for the duration of a test
+func withFacts(t *testing.T, overrides map[string]string) {
+	for name, value := range overrides {
+		original := facts.String(name)
+		if err := facts.Override(name, value); err != nil {
+			t.Fatal(err)
+		}
+		name := name
+		t.Cleanup(func() {
+			_ = facts.Override(name, original)
+			_ = os.Unsetenv(facts.EnvPrefix + strings.ToUpper(name))
+		})
+	}
+}
+
+func testAssets(names ...string) []githubAsset {
+	assets := make([]githubAsset, 0, len(names))
+	for _, name := range names {
+		assets = append(assets, githubAsset{Name: name, Url: "http://localhost/" + name})
+	}
+	return assets
+}
+
+func TestSelectAsset(t *testing.T) {
+	ripgrep := testAssets(
+		"ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz",
+		"ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz.sha256",
+		"ripgrep-14.1.0-aarch64-unknown-linux-gnu.tar.gz",
+		"ripgrep-14.1.0-i686-unknown-linux-gnu.tar.gz",
+		"ripgrep-14.1.0-armv7-unknown-linux-gnueabihf.tar.gz",
+		"ripgrep-14.1.0-x86_64-apple-darwin.tar.gz",
+		"ripgrep-14.1.0-aarch64-apple-darwin.tar.gz",
+		"ripgrep-14.1.0-x86_64-pc-windows-msvc.zip",
+		"ripgrep_14.1.0-1_amd64.deb",
+	)
+	libc := testAssets(
+		"tool-1.0.0-x86_64-unknown-linux-gnu.tar.gz",
+		"tool-1.0.0-x86_64-unknown-linux-musl.tar.gz",
+		"tool-1.0.0-arm64-unknown-linux-gnu.tar.gz",
+	)
+	binaries := testAssets(
+		"tool_checksums.txt",
+		"tool-linux-amd64",
+		"tool-linux-arm64",
+		"tool-darwin-universal",
+		"tool-windows-amd64.exe",
+	)
+
+	tests := []struct {
+		name   string
+		facts  map[string]string
+		config GithubReleaseConfig
+		assets []githubAsset
+		want   string
+	}{
+		{"static musl on glibc", map[string]string{"os": "linux", "arch": "amd64", "libc": "gnu"}, GithubReleaseConfig{}, ripgrep, "ripgrep-14.1.0-x86_64-unknown-linux-musl.tar.gz"},
+		{"linux arm64", map[string]string{"os": "linux", "arch": "arm64", "libc": "gnu"}, GithubReleaseConfig{}, ripgrep, "ripgrep-14.1.0-aarch64-unknown-linux-gnu.tar.gz"},
+		{"linux 386", map[string]string{"os": "linux", "arch": "386", "libc": "gnu"}, GithubReleaseConfig{}, ripgrep, "ripgrep-14.1.0-i686-unknown-linux-gnu.tar.gz"},
+		{"linux arm", map[string]string{"os": "linux", "arch": "arm", "libc": "gnu"}, GithubReleaseConfig{}, ripgrep, "ripgrep-14.1.0-armv7-unknown-linux-gnueabihf.tar.gz"},
+		{"darwin arm64", map[string]string{"os": "darwin", "arch": "arm64"}, GithubReleaseConfig{}, ripgrep, "ripgrep-14.1.0-aarch64-apple-darwin.tar.gz"},
+		{"windows", map[string]string{"os": "windows", "arch": "amd64"}, GithubReleaseConfig{}, ripgrep, "ripgrep-14.1.0-x86_64-pc-windows-msvc.zip"},
+		{"prefer gnu", map[string]string{"os": "linux", "arch": "amd64", "libc": "gnu"}, GithubReleaseConfig{}, libc, "tool-1.0.0-x86_64-unknown-linux-gnu.tar.gz"},
+		{"prefer musl", map[string]string{"os": "linux", "arch": "amd64", "libc": "musl"}, GithubReleaseConfig{}, libc, "tool-1.0.0-x86_64-unknown-linux-musl.tar.gz"},
+		{"libc option", map[string]string{"os": "linux", "arch": "amd64", "libc": "gnu"}, GithubReleaseConfig{Libc: []string{"musl"}}, libc, "tool-1.0.0-x86_64-unknown-linux-musl.tar.gz"},
+		{"arch option", map[string]string{"os": "linux", "arch": "amd64", "libc": "gnu"}, GithubReleaseConfig{Arch: []string{"arm64"}}, libc, "tool-1.0.0-arm64-unknown-linux-gnu.tar.gz"},
+		{"exclude", map[string]string{"os": "linux", "arch": "amd64", "libc": "gnu"}, GithubReleaseConfig{Exclude: []string{"*gnu*"}}, libc, "tool-1.0.0-x86_64-unknown-linux-musl.tar.gz"},
+		{"asset pattern", map[string]string{"os": "linux", "arch": "amd64"}, GithubReleaseConfig{Asset: "*darwin*"}, ripgrep, "ripgrep-14.1.0-x86_64-apple-darwin.tar.gz"},
+		{"binary", map[string]string{"os": "linux", "arch": "arm64", "libc": "gnu"}, GithubReleaseConfig{}, binaries, "tool-linux-arm64"},
+		{"universal binary", map[string]string{"os": "darwin", "arch": "arm64"}, GithubReleaseConfig{}, binaries, "tool-darwin-universal"},
+		{"windows binary", map[string]string{"os": "windows", "arch": "amd64"}, GithubReleaseConfig{}, binaries, "tool-windows-amd64.exe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withFacts(t, tt.facts)
+			tt.config.Repo = "owner/tool"
+			asset, err := tt.config.selectAsset(tt.assets)
+			if err != nil {
+				t.Fatalf("selectAsset() error: %v", err)
+			}
+			if asset.Name != tt.want {
+				t.Errorf("selectAsset() = %s, want %s", asset.Name, tt.want)
+			}
+		})
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		withFacts(t, map[string]string{"os": "freebsd", "arch": "amd64"})
+		_, err := GithubReleaseConfig{Repo: "owner/tool"}.selectAsset(ripgrep)
+		if err == nil || !strings.Contains(err.Error(), "matches freebsd/amd64") {
+			t.Errorf("selectAsset() error = %v, want no match for freebsd/amd64", err)
+		}
+	})
+}
+
+func TestPlaceItems(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tool-1.0.0")
+	files := map[string]os.FileMode{
+		"tool":                   0755,
+		"tool-helper":            0755,
+		"README.md":              0644,
+		"LICENSE":                0644,
+		"doc/tool.1":             0644,
+		"doc/tool-helper.5.gz":   0644,
+		"complete/_tool":         0644,
+		"complete/tool.bash":     0644,
+		"complete/tool.fish":     0644,
+		"autocomplete/tool2.zsh": 0644,
+	}
+	for name, mode := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	archive := filepath.Join(t.TempDir(), "tool.tar.gz")
+	if err := archiver.Archive([]string{dir}, archive); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config GithubReleaseConfig
+		want   []string
+	}{
+		{"all executables", GithubReleaseConfig{Repo: "owner/tool"}, []string{
+			"tool-1.0.0/autocomplete/tool2.zsh -> /local/share/zsh/site-functions/#/_tool2",
+			"tool-1.0.0/complete/_tool -> /local/share/zsh/site-functions",
+			"tool-1.0.0/complete/tool.bash -> /local/share/bash-completion/completions/#/tool",
+			"tool-1.0.0/complete/tool.fish -> /local/share/fish/vendor_completions.d",
+			"tool-1.0.0/doc/tool-helper.5.gz -> /local/share/man/man5",
+			"tool-1.0.0/doc/tool.1 -> /local/share/man/man1",
+			"tool-1.0.0/tool -> /local/bin",
+			"tool-1.0.0/tool-helper -> /local/bin",
+		}},
+		{"only binaries", GithubReleaseConfig{Repo: "owner/tool", Binaries: []string{"tool"}}, []string{
+			"tool-1.0.0/autocomplete/tool2.zsh -> /local/share/zsh/site-functions/#/_tool2",
+			"tool-1.0.0/complete/_tool -> /local/share/zsh/site-functions",
+			"tool-1.0.0/complete/tool.bash -> /local/share/bash-completion/completions/#/tool",
+			"tool-1.0.0/complete/tool.fish -> /local/share/fish/vendor_completions.d",
+			"tool-1.0.0/doc/tool-helper.5.gz -> /local/share/man/man5",
+			"tool-1.0.0/doc/tool.1 -> /local/share/man/man1",
+			"tool-1.0.0/tool -> /local/bin",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := tt.config.placeItems(archive, "/local")
+			if err != nil {
+				t.Fatalf("placeItems() error: %v", err)
+			}
+			got := make([]string, 0, len(items))
+			for _, item := range items {
+				got = append(got, item.Source+" -> "+item.Path)
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("placeItems() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestResolveRelease(t *testing.T) {
+	// releases are listed from newest to oldest across pages
+	pages := [][]githubRelease{
+		{{TagName: "v2.0.0-rc.1", Prerelease: true}, {TagName: "v1.3.0"}},
+		{{TagName: "v1.2.1"}, {TagName: "v1.2.0"}},
+		{{TagName: "v1.1.0"}, {TagName: "v1.0.0"}},
+	}
+	var requested []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/tool/releases/latest":
+			_ = json.NewEncoder(w).Encode(pages[0][1])
+		case "/repos/owner/tool/releases":
+			page := r.URL.Query().Get("page")
+			requested = append(requested, page)
+			i := 0
+			if page != "" {
+				i = int(page[0] - '1')
+			}
+			if i+1 < len(pages) {
+				w.Header().Set("Link", fmt.Sprintf(
+					`<%s/repos/owner/tool/releases?per_page=100&page=%d>; rel="next", <%s/repos/owner/tool/releases?per_page=100&page=%d>; rel="last"`,
+					server.URL, i+2, server.URL, len(pages),
+				))
+			}
+			_ = json.NewEncoder(w).Encode(pages[i])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		version   VersionConstraint
+		want      string
+		wantNewer string
+		wantPages int
+	}{
+		{"latest", VersionConstraint{}, "1.3.0", "", 0},
+		{"constraint", VersionConstraint{Constraint: "~1.2"}, "1.2.1", "1.3.0", 2},
+		{"pin", VersionConstraint{Pin: "1.2.0"}, "1.2.0", "1.3.0", 2},
+		{"pin latest", VersionConstraint{Pin: "1.3.0"}, "1.3.0", "", 1},
+		{"pin last page", VersionConstraint{Pin: "1.0.0"}, "1.0.0", "1.3.0", 3},
+		{"prerelease", VersionConstraint{Constraint: ">=1.0.0-0", Prerelease: true}, "2.0.0-rc.1", "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+			config := GithubReleaseConfig{Repo: "owner/tool", Api: server.URL}
+			config.Version.VersionConstraint = tt.version
+			release, newer, err := config.resolveRelease()
+			if err != nil {
+				t.Fatalf("resolveRelease() error: %v", err)
+			}
+			if release.version() != tt.want || newer != tt.wantNewer {
+				t.Errorf("resolveRelease() = %s, %q, want %s, %q", release.version(), newer, tt.want, tt.wantNewer)
+			}
+			if len(requested) != tt.wantPages {
+				t.Errorf("fetched pages %q, want %d pages", requested, tt.wantPages)
+			}
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		config := GithubReleaseConfig{Repo: "owner/tool", Api: server.URL}
+		config.Version.Pin = "0.1.0"
+		if _, _, err := config.resolveRelease(); err == nil || !strings.Contains(err.Error(), "no release of owner/tool has version 0.1.0") {
+			t.Errorf("resolveRelease() error = %v, want no release", err)
+		}
+	})
+}
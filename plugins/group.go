@@ -54,3 +54,33 @@ func (b GroupBase) RunAll() error {
 	}
 	return nil
 }
+
+type GroupInfo struct {
+	Name       string      `json:"name"`
+	Directives int         `json:"directives"`
+	Groups     []GroupInfo `json:"groups,omitempty"`
+}
+
+// GroupTree returns the groups defined in the list, groups nested
+// inside other groups are returned as children of their parent
+func (c PluginList) GroupTree() []GroupInfo {
+	groups := make([]GroupInfo, 0)
+	for _, item := range c {
+		for _, plugin := range item {
+			if b, ok := plugin.(*GroupBase); ok {
+				for _, config := range *b {
+					groups = append(groups, GroupInfo{
+						Name:       config.Name,
+						Directives: config.Config.Len(),
+						Groups:     config.Config.GroupTree(),
+					})
+				}
+				continue
+			}
+			for _, list := range nestedLists(plugin) {
+				groups = append(groups, list.GroupTree()...)
+			}
+		}
+	}
+	return groups
+}
@@ -1,7 +1,9 @@
 package plugins
 
 import (
+	"errors"
 	"fmt"
+	"github.com/jcwillox/dotbot/expr"
 	"github.com/jcwillox/dotbot/template"
 	"github.com/jcwillox/dotbot/yamltools"
 	"gopkg.in/yaml.v3"
@@ -10,10 +12,49 @@ import (
 type IfBase []IfConfig
 type IfConfig struct {
 	Condition FlatList
+	Expr      *Expression `yaml:",omitempty"`
 	Then      PluginList
 	Else      PluginList
 }
 
+// Expression is a boolean expression which is compiled when the config is read
+type Expression struct {
+	program *expr.Program
+	line    int
+}
+
+func (e *Expression) UnmarshalYAML(n *yaml.Node) error {
+	var source string
+	err := n.Decode(&source)
+	if err != nil {
+		return err
+	}
+	program, err := expr.Compile(source)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid expression: %s", n.Line, err)
+	}
+	e.program = program
+	e.line = n.Line
+	return nil
+}
+
+func (e Expression) MarshalYAML() (interface{}, error) {
+	return e.program.String(), nil
+}
+
+func (e Expression) String() string {
+	return e.program.String()
+}
+
+// Eval returns the result of the expression, errors include the line it is defined on
+func (e Expression) Eval() (bool, error) {
+	result, err := e.program.RunBool()
+	if err != nil {
+		return false, fmt.Errorf("line %d: %s", e.line, err)
+	}
+	return result, nil
+}
+
 func (b *IfBase) UnmarshalYAML(n *yaml.Node) error {
 	n = yamltools.EnsureList(n)
 	type IfBaseT IfBase
@@ -29,26 +70,46 @@ func (b IfBase) Enabled() bool {
 }
 
 func (b IfBase) RunAll() error {
+	hasError := false
 	for _, config := range b {
 		err := config.Run()
 		if err != nil {
-			fmt.Println("ERROR:", err)
+			reportError(err)
+			hasError = true
 		}
 	}
+	if hasError {
+		return errors.New("failed to run some conditions")
+	}
 	return nil
 }
 
 func (c IfConfig) Run() error {
+	if c.Expr != nil {
+		result, err := c.Expr.Eval()
+		if err != nil {
+			return err
+		}
+		if !result {
+			return runBranch(c.Else, "else")
+		}
+	}
 	for _, condition := range c.Condition {
 		result, err := template.Parse(condition).RenderTrue()
 		if err != nil {
 			return err
 		}
 		if !result {
-			c.Else.RunAll()
-			return nil
+			return runBranch(c.Else, "else")
 		}
 	}
-	c.Then.RunAll()
+	return runBranch(c.Then, "then")
+}
+
+// runBranch runs the directives of a branch, their errors have already been printed
+func runBranch(branch PluginList, name string) error {
+	if runNested(branch) != nil {
+		return errors.New("failed to run some directives in " + name)
+	}
 	return nil
 }
@@ -6,33 +6,56 @@ import (
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/store"
 	"github.com/jcwillox/dotbot/template"
+	"github.com/jcwillox/dotbot/utils"
 	"github.com/jcwillox/dotbot/utils/sudo"
 	"github.com/jcwillox/dotbot/yamltools"
 	"github.com/jcwillox/emerald"
 	"gopkg.in/yaml.v3"
 	"io"
 	"net/http"
+	"os"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 )
 
 var installLogger = log.NewBasicLogger("INSTALL")
 
 type InstallBase []InstallConfig
 type InstallConfig struct {
-	Name     string
-	Url      string
-	Version  InstallVersion
-	Download *DownloadConfig
-	Shell    *ShellConfig
-	Sudo     bool
-	TrySudo  bool `yaml:"try_sudo"`
-	Then     PluginList
+	Name        string
+	Url         string
+	Version     InstallVersion
+	Download    *DownloadConfig
+	Sha256      string `yaml:",omitempty"`
+	ChecksumUrl string `yaml:"checksum_url,omitempty"`
+	Signature   string `yaml:",omitempty"`
+	PublicKey   string `yaml:"public_key,omitempty"`
+	Creates     string `yaml:",omitempty"`
+	Shell       *ShellConfig
+	Sudo        bool
+	TrySudo     bool `yaml:"try_sudo"`
+	Then        PluginList
 }
 type InstallVersion struct {
-	Url   string `yaml:",omitempty"`
-	Regex string
+	Url               string `yaml:",omitempty"`
+	Regex             string
+	Current           *VersionProbe `yaml:",omitempty"`
+	VersionConstraint `yaml:",inline"`
+}
+
+// VersionProbe detects the installed version by running the tool
+type VersionProbe struct {
+	Command string
+	Regex   string `yaml:",omitempty"`
+}
+
+// VersionConstraint limits which released versions are installed
+type VersionConstraint struct {
+	Constraint string `yaml:",omitempty"`
+	Pin        string `yaml:",omitempty"`
+	Prerelease bool   `yaml:",omitempty"`
 }
 
 func (b *InstallBase) UnmarshalYAML(n *yaml.Node) error {
@@ -44,7 +67,159 @@ func (b *InstallBase) UnmarshalYAML(n *yaml.Node) error {
 func (c *InstallVersion) UnmarshalYAML(n *yaml.Node) error {
 	n = yamltools.ScalarToMapVal(n, "regex")
 	type VersionConfigT InstallVersion
-	return n.Decode((*VersionConfigT)(c))
+	err := n.Decode((*VersionConfigT)(c))
+	if err != nil {
+		return err
+	}
+	if err := c.VersionConstraint.validate(); err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	return nil
+}
+
+func (c *VersionProbe) UnmarshalYAML(n *yaml.Node) error {
+	n = yamltools.ScalarToMapVal(n, "command")
+	type VersionProbeT VersionProbe
+	err := n.Decode((*VersionProbeT)(c))
+	if err != nil {
+		return err
+	}
+	if c.Command == "" {
+		return fmt.Errorf("line %d: version probe requires a command", n.Line)
+	}
+	if _, err := regexp.Compile(c.Regex); err != nil {
+		return fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	return nil
+}
+
+// probeRegex matches the first version in the output when no regex is given
+var probeRegex = regexp.MustCompile(`v?(\d+(?:\.\d+)+(?:-[0-9A-Za-z.]+)?)`)
+
+// Probe runs the command and returns the version in its output
+func (c VersionProbe) Probe() (string, error) {
+	err := template.RenderField(&c.Command)
+	if err != nil {
+		return "", err
+	}
+	cmd, err := utils.Command{Command: c.Command, Shell: true}.Cmd()
+	if err != nil {
+		return "", err
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("'%s' failed: %w", c.Command, err)
+	}
+	versionRegex := probeRegex
+	if c.Regex != "" {
+		versionRegex = regexp.MustCompile(c.Regex)
+	}
+	matches := versionRegex.FindSubmatch(out)
+	if matches == nil {
+		return "", fmt.Errorf("no version in output of '%s'", c.Command)
+	} else if len(matches) > 1 {
+		return string(matches[1]), nil
+	}
+	return string(matches[0]), nil
+}
+
+func (c VersionConstraint) validate() error {
+	if c.Constraint == "" {
+		return nil
+	}
+	constraint, err := utils.ParseConstraint(c.Constraint)
+	if err != nil {
+		return err
+	}
+	if c.Pin != "" {
+		pin, err := utils.ParseVersion(c.Pin)
+		if err != nil {
+			return err
+		}
+		if !constraint.Check(pin) {
+			return fmt.Errorf("pinned version %s does not satisfy '%s'", c.Pin, c.Constraint)
+		}
+	}
+	return nil
+}
+
+// listed returns true if all versions must be listed to choose one,
+// rather than only looking up the latest version
+func (c VersionConstraint) listed() bool {
+	return c.Constraint != "" || c.Prerelease
+}
+
+// Select returns the highest version that satisfies the constraint, and the
+// highest version if it is newer but excluded by the constraint
+func (c VersionConstraint) Select(versions []string) (string, string, error) {
+	var constraint utils.Constraint
+	if c.Constraint != "" {
+		var err error
+		constraint, err = utils.ParseConstraint(c.Constraint)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	var selected, latest string
+	var selectedVersion, latestVersion utils.Version
+	for _, s := range versions {
+		v, err := utils.ParseVersion(s)
+		if err != nil {
+			log.Debugln("skipping version", s, err)
+			continue
+		}
+		if v.IsPrerelease() && !c.Prerelease {
+			continue
+		}
+		if latest == "" || v.Compare(latestVersion) > 0 {
+			latest, latestVersion = s, v
+		}
+		if (constraint == nil || constraint.Check(v)) && (selected == "" || v.Compare(selectedVersion) > 0) {
+			selected, selectedVersion = s, v
+		}
+	}
+	if selected == "" {
+		if latest == "" {
+			return "", "", errors.New("no versions found")
+		}
+		return "", "", fmt.Errorf("no version satisfies '%s', latest is %s", c.Constraint, latest)
+	}
+	if latest == selected {
+		latest = ""
+	}
+	return selected, latest, nil
+}
+
+// matches returns true if the version is the pinned version, or satisfies the
+// constraint when it is not pinned
+func (c VersionConstraint) matches(version string) bool {
+	if c.Pin != "" {
+		return version == c.Pin
+	}
+	v, err := utils.ParseVersion(version)
+	if err != nil || (v.IsPrerelease() && !c.Prerelease) {
+		return false
+	}
+	if c.Constraint == "" {
+		return true
+	}
+	constraint, err := utils.ParseConstraint(c.Constraint)
+	return err == nil && constraint.Check(v)
+}
+
+// newerThanPin returns the latest version if it is newer than the pinned version
+func (c VersionConstraint) newerThanPin(versions []string) string {
+	if c.Pin == "" {
+		return ""
+	}
+	latest, _, err := VersionConstraint{Prerelease: c.Prerelease}.Select(versions)
+	if err != nil {
+		return ""
+	}
+	if cmp, err := utils.CompareVersions(latest, c.Pin); err == nil && cmp > 0 {
+		return latest
+	}
+	return ""
 }
 
 func (b InstallBase) Enabled() bool {
@@ -55,12 +230,24 @@ func (b InstallBase) RunAll() error {
 	for _, config := range b {
 		err := config.Run()
 		if err != nil {
-			fmt.Println("ERROR:", err)
+			reportError(err)
 		}
 	}
 	return nil
 }
 
+// logHeld notes a newer version that is not installed due to the pin or constraint
+func logHeld(title string, newer string, version VersionConstraint) {
+	reason := " is outside " + version.Constraint
+	if version.Pin != "" {
+		reason = " is newer than the pinned " + version.Pin
+	}
+	installLogger.TagC(emerald.Yellow, "held").Print(
+		emerald.Green, title, " ", highlightVersion(newer),
+		emerald.LightBlack, reason, emerald.Reset, "\n",
+	)
+}
+
 func logInstall(title string, version string, latest string) {
 	if version == "" && latest == "" {
 		installLogger.TagC(emerald.Red, "invalid").Print(emerald.Green, title, "\n")
@@ -79,54 +266,183 @@ func logInstall(title string, version string, latest string) {
 }
 
 func (c InstallConfig) Run() error {
-	version, err := GetVersion(c.Url, &c.Version)
+	version, newer, err := ResolveVersion(c.Url, &c.Version)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s: %w", c.String(), err)
 	}
 	if version == "" {
 		return errors.New("latest version was empty")
 	}
-	current := store.Get(c.Version.Url)
 
+	// checksums and signatures apply to the shorthand download
+	if c.Sha256 != "" || c.ChecksumUrl != "" || c.Signature != "" || c.PublicKey != "" {
+		if c.Download == nil {
+			return fmt.Errorf("%s: sha256, checksum_url, signature and public_key require download", c.String())
+		}
+		if c.Download.Sha256 == "" && c.Download.ChecksumUrl == "" {
+			c.Download.Sha256, c.Download.ChecksumUrl = c.Sha256, c.ChecksumUrl
+		}
+		if c.Download.Signature == "" {
+			c.Download.Signature = c.Signature
+		}
+		if c.Download.PublicKey == "" {
+			c.Download.PublicKey = c.PublicKey
+		}
+	}
+
+	// merge shorthand directives into then block
+	then := make(PluginList, 0, 2)
+	if c.Download != nil {
+		then = append(then, map[string]Plugin{"download": DownloadBase{c.Download}})
+	}
+	if c.Shell != nil {
+		then = append(then, map[string]Plugin{"shell": ShellBase{c.Shell}})
+	}
+	if len(then) > 0 {
+		c.Then = append(then, c.Then...)
+	}
+	err = c.install(version, c.runThen)
+	if newer != "" {
+		logHeld(c.String(), newer, c.Version.VersionConstraint)
+	}
+	return err
+}
+
+// install calls run when version differs from the installed version,
+// recording the result in the install history and state file
+func (c InstallConfig) install(version string, run func() error) error {
 	// abort early if we don't have root privileges
 	if c.Sudo && !sudo.CanSudo() {
 		return nil
 	}
 
+	current := c.current(version)
+
 	logInstall(c.String(), current, version)
-	if current != version {
-		defer store.VarsClosure(map[string]interface{}{"Current": current, "Version": version, "Url": c.Url})()
+	if current == version {
+		return c.rekey()
+	}
+	defer store.VarsClosure(map[string]interface{}{"Current": current, "Version": version, "Url": c.Url})()
 
-		// merge shorthand directives into then block
-		then := make(PluginList, 0, 2)
-		if c.Download != nil {
-			then = append(then, map[string]Plugin{"download": DownloadBase{c.Download}})
+	start := time.Now()
+	failed := failedTasks
+	err := run()
+	if err == nil && failedTasks > failed {
+		// directives such as shell report their errors rather than returning them
+		err = fmt.Errorf("%d tasks failed", failedTasks-failed)
+	}
+	if !store.DryRun {
+		event := store.Event{
+			Tool:     c.String(),
+			Time:     start,
+			Previous: current,
+			Version:  version,
+			Success:  err == nil,
+			Duration: time.Since(start),
 		}
-		if c.Shell != nil {
-			then = append(then, map[string]Plugin{"shell": ShellBase{c.Shell}})
+		if err != nil {
+			event.Error = err.Error()
 		}
-		if len(then) > 0 {
-			c.Then = append(then, c.Then...)
+		if err := store.AddEvent(event); err != nil {
+			log.Warnln("failed to record install history", err)
 		}
+	}
+	if err != nil {
+		// keep the previous version so the install is retried
+		return fmt.Errorf("failed to install %s %s: %w", c.String(), version, err)
+	}
 
-		if c.Sudo || c.TrySudo {
-			if sudo.WouldSudo() {
-				err := sudo.Configs(&c.Then)
-				if err != nil {
-					return err
-				}
-			} else if sudo.IsRoot() || c.TrySudo {
-				c.Then.RunAll()
-			}
-		} else {
-			c.Then.RunAll()
+	if !store.DryRun {
+		if key := c.StateKeys()[1]; key != c.String() {
+			store.UnsetInstall(key)
 		}
+		return store.SetInstall(c.String(), store.Install{Version: version, Url: c.Version.Url, Time: &start})
+	}
+	return nil
+}
 
-		if !store.DryRun {
-			store.SetSave(c.Version.Url, version)
+func (c InstallConfig) runThen() error {
+	if c.Sudo || c.TrySudo {
+		if sudo.WouldSudo() {
+			return sudo.Configs(&c.Then)
+		} else if sudo.IsRoot() || c.TrySudo {
+			return c.Then.RunAll()
 		}
+		return nil
 	}
-	return nil
+	return c.Then.RunAll()
+}
+
+// current returns the installed version, when a probe or created path is
+// configured the system is checked so that tools which were removed or
+// installed by other means are detected, failing either means not installed.
+// Installs cleared by state --reinstall are not probed so they are installed
+// again, whereas removing the record with state unset only forgets it.
+func (c InstallConfig) current(version string) string {
+	install, recorded := c.installed()
+	if recorded && install.Version == "" {
+		return ""
+	}
+	current := install.Version
+	if c.Creates != "" {
+		creates := c.Creates
+		err := template.RenderField(&creates)
+		if err != nil {
+			log.Warnln("failed to render creates", err)
+			return ""
+		}
+		if _, err := os.Stat(utils.ExpandUser(creates)); err != nil {
+			log.Debugln("treating", c.String(), "as not installed:", err)
+			return ""
+		}
+	}
+	if c.Version.Current != nil {
+		probed, err := c.Version.Current.Probe()
+		if err != nil {
+			log.Debugln("treating", c.String(), "as not installed:", err)
+			return ""
+		}
+		current = probed
+	}
+	// versions such as 1.4 and v1.4.0 are the same
+	if cmp, err := utils.CompareVersions(current, version); err == nil && cmp == 0 {
+		return version
+	}
+	return current
+}
+
+// installed returns the recorded install
+func (c InstallConfig) installed() (store.Install, bool) {
+	for _, key := range c.StateKeys() {
+		if install, ok := store.GetInstall(key); ok {
+			return install, true
+		}
+	}
+	return store.Install{}, false
+}
+
+// rekey records an install migrated from an older state file under its name
+func (c InstallConfig) rekey() error {
+	keys := c.StateKeys()
+	if _, ok := store.GetInstall(keys[0]); ok || store.DryRun {
+		return nil
+	}
+	install, ok := store.GetInstall(keys[1])
+	if !ok {
+		return nil
+	}
+	store.UnsetInstall(keys[1])
+	return store.SetInstall(keys[0], install)
+}
+
+// StateKeys returns the keys the install may be recorded under in the state file,
+// installs migrated from older state files are keyed by the url used to check their version
+func (c InstallConfig) StateKeys() []string {
+	url := c.Version.Url
+	if strings.HasPrefix(url, "/") || url == "" {
+		url = c.Url + url
+	}
+	return []string{c.String(), url}
 }
 
 func (c InstallConfig) String() string {
@@ -154,6 +470,58 @@ func GetVersion(baseUrl string, config *InstallVersion) (string, error) {
 	return "", errors.New("could not determine method to extract version")
 }
 
+// ResolveVersion returns the version to install, and a newer version
+// if one exists that is excluded by the constraint
+func ResolveVersion(baseUrl string, config *InstallVersion) (string, string, error) {
+	if strings.HasPrefix(config.Url, "/") || config.Url == "" {
+		config.Url = baseUrl + config.Url
+	}
+	if config.Pin != "" {
+		// the pinned version is installed even if newer versions cannot be listed
+		versions, err := ListVersions(baseUrl, config)
+		if err != nil {
+			log.Debugln("failed to list versions newer than", config.Pin, err)
+			return config.Pin, "", nil
+		}
+		return config.Pin, config.newerThanPin(versions), nil
+	}
+	if !config.listed() {
+		version, err := GetVersion(baseUrl, config)
+		return version, "", err
+	}
+	versions, err := ListVersions(baseUrl, config)
+	if err != nil {
+		return "", "", err
+	}
+	return config.Select(versions)
+}
+
+// ListVersions returns all released versions, GitHub releases
+// marked as prereleases are excluded unless they are allowed
+func ListVersions(baseUrl string, config *InstallVersion) ([]string, error) {
+	if strings.HasPrefix(config.Url, "/") || config.Url == "" {
+		config.Url = baseUrl + config.Url
+	}
+	if config.Regex != "" {
+		return GetRegexVersions(*config)
+	} else if strings.HasPrefix(config.Url, "https://github.com/") {
+		repo := strings.TrimPrefix(config.Url, "https://github.com/")
+		repo = strings.TrimSuffix(strings.TrimRight(repo, "/"), "/releases/latest")
+		releases, err := listGithubReleases(githubApi(""), repo, config.VersionConstraint)
+		if err != nil {
+			return nil, err
+		}
+		versions := make([]string, 0, len(releases))
+		for _, release := range releases {
+			if !release.Prerelease || config.Prerelease {
+				versions = append(versions, release.version())
+			}
+		}
+		return versions, nil
+	}
+	return nil, errors.New("could not determine method to list versions")
+}
+
 func GetGithubVersion(url string) (string, error) {
 	if !strings.HasSuffix(url, "/releases/latest") {
 		url = strings.TrimRight(url, "/") + "/releases/latest"
@@ -173,28 +541,49 @@ func GetRegexVersion(c InstallVersion) (string, error) {
 	if template.HasTemplate(c.Regex) {
 		return template.Parse(c.Regex).Render()
 	} else {
-		versionRegex, err := regexp.Compile(c.Regex)
-		if err != nil {
+		matches, err := fetchRegexMatches(c)
+		if err != nil || len(matches) == 0 {
 			return "", err
 		}
-		resp, err := http.Get(c.Url)
-		if err != nil {
-			return "", err
-		}
-		data, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "", err
-		}
-		log.Debugln("fetching", c.Url)
-		matches := versionRegex.FindSubmatch(data)
+		return matches[0], nil
+	}
+}
+
+// GetRegexVersions returns every version matched by the regex
+func GetRegexVersions(c InstallVersion) ([]string, error) {
+	if template.HasTemplate(c.Regex) {
+		version, err := template.Parse(c.Regex).Render()
+		return []string{version}, err
+	}
+	return fetchRegexMatches(c)
+}
+
+// fetchRegexMatches returns the first group of each match or the whole match
+func fetchRegexMatches(c InstallVersion) ([]string, error) {
+	versionRegex, err := regexp.Compile(c.Regex)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(c.Url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugln("fetching", c.Url)
+	versions := make([]string, 0, 1)
+	for _, matches := range versionRegex.FindAllSubmatch(data, -1) {
 		for i, match := range matches {
 			log.Debugf("[match] %d: %s\n", i, match)
 		}
 		if len(matches) > 1 {
-			return string(matches[1]), nil
-		} else if matches == nil {
-			return "", nil
+			versions = append(versions, string(matches[1]))
+		} else {
+			versions = append(versions, string(matches[0]))
 		}
-		return string(matches[0]), nil
 	}
+	return versions, nil
 }
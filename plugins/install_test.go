@@ -0,0 +1,49 @@
+package plugins
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveVersion(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tool-1.2.0.tar.gz tool-1.3.0.tar.gz tool-2.0.0-rc.1.tar.gz"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		version   VersionConstraint
+		want      string
+		wantNewer string
+	}{
+		{"constraint", VersionConstraint{Constraint: "~1.2"}, "1.2.0", "1.3.0"},
+		{"pin", VersionConstraint{Pin: "1.2.0"}, "1.2.0", "1.3.0"},
+		{"pin latest", VersionConstraint{Pin: "1.3.0"}, "1.3.0", ""},
+		{"pin prerelease", VersionConstraint{Pin: "1.3.0", Prerelease: true}, "1.3.0", "2.0.0-rc.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := InstallVersion{Url: "/releases", Regex: `tool-([^ ]+)\.tar\.gz`, VersionConstraint: tt.version}
+			version, newer, err := ResolveVersion(server.URL, &config)
+			if err != nil {
+				t.Fatalf("ResolveVersion() error: %v", err)
+			}
+			if version != tt.want || newer != tt.wantNewer {
+				t.Errorf("ResolveVersion() = %s, %q, want %s, %q", version, newer, tt.want, tt.wantNewer)
+			}
+			if config.Url != server.URL+"/releases" {
+				t.Errorf("url = %s, want it resolved against the base url", config.Url)
+			}
+		})
+	}
+
+	t.Run("pin without listing", func(t *testing.T) {
+		config := InstallVersion{VersionConstraint: VersionConstraint{Pin: "1.0.0"}}
+		version, newer, err := ResolveVersion("http://localhost:1/", &config)
+		if err != nil || version != "1.0.0" || newer != "" {
+			t.Errorf("ResolveVersion() = %s, %q, %v, want the pinned version", version, newer, err)
+		}
+	})
+}
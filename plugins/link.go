@@ -1,9 +1,12 @@
 package plugins
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/creasty/defaults"
+	"github.com/jcwillox/dotbot/backup"
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/store"
 	"github.com/jcwillox/dotbot/template"
@@ -13,8 +16,9 @@ import (
 	"github.com/jcwillox/emerald"
 	"gopkg.in/yaml.v3"
 	"os"
+	"path"
 	"path/filepath"
-	"strconv"
+	"strings"
 )
 
 var linkLogger = log.NewBasicLogger("LINK")
@@ -26,6 +30,32 @@ type LinkConfig struct {
 	Mkdirs    bool `default:"true"`
 	Force     bool
 	SafeForce bool `yaml:"safe_force"`
+	// Relative creates symlinks with a target relative to the link
+	Relative bool
+	// Type is either symlink or hard
+	Type string `default:"symlink"`
+	// Mode is either link or copy
+	Mode string `default:"link"`
+	// Pull copies changes made to a deployed copy back into the dotfiles directory
+	Pull bool
+	// Prefix is prepended to the name of each link created from a glob source
+	Prefix string `yaml:",omitempty"`
+	// StripExt removes the extension from links created from a glob source
+	StripExt bool `yaml:"strip_ext,omitempty"`
+	// Exclude skips matches of a glob source
+	Exclude FlatList `yaml:",omitempty"`
+	// Adopt moves an existing target into the dotfiles directory before linking
+	Adopt bool
+	// glob is the record of the glob source the link was expanded from
+	glob string
+}
+
+// linkGlob is recorded for each link created from a glob source,
+// so that links can be cleaned once their source no longer matches
+type linkGlob struct {
+	Base    string   `json:"base"`
+	Pattern string   `json:"pattern"`
+	Exclude []string `json:"exclude,omitempty"`
 }
 
 func (b *LinkBase) UnmarshalYAML(n *yaml.Node) error {
@@ -47,10 +77,11 @@ func (c *LinkConfig) UnmarshalYAML(n *yaml.Node) error {
 }
 
 func (c *LinkConfig) MarshalYAML() (interface{}, error) {
-	path := c.Path
-	c.Path = ""
+	// marshal a copy as the path is still needed after passing configs to sudo
+	config := *c
+	config.Path = ""
 	type LinkConfigT LinkConfig
-	return map[string]*LinkConfigT{path: (*LinkConfigT)(c)}, nil
+	return map[string]*LinkConfigT{c.Path: (*LinkConfigT)(&config)}, nil
 }
 
 func (b LinkBase) Enabled() bool {
@@ -58,7 +89,16 @@ func (b LinkBase) Enabled() bool {
 }
 
 func (b LinkBase) RunAll() error {
+	configs := make([]*LinkConfig, 0, len(b))
 	for _, config := range b {
+		expanded, err := config.expandGlob()
+		if err != nil {
+			reportError(err)
+			continue
+		}
+		configs = append(configs, expanded...)
+	}
+	for _, config := range configs {
 		err := config.Run()
 		if sudo.IsPermission(err) && sudo.WouldSudo() {
 			absSource, _ := filepath.Abs(config.Source)
@@ -69,9 +109,13 @@ func (b LinkBase) RunAll() error {
 				)
 			}
 			err = sudo.Config("link", &config)
+			if err == nil {
+				err = config.recordGlob()
+			}
 		}
 		if err != nil {
 			fmt.Println("error:", err)
+			taskFailed()
 		}
 	}
 	return nil
@@ -84,11 +128,35 @@ func (c LinkConfig) Run() error {
 	}
 
 	source := utils.ExpandUser(c.Source)
+	path := utils.ExpandUser(c.Path)
+	if c.Adopt || store.Adopt {
+		done, err := c.adoptTarget(source, path)
+		if err != nil || done {
+			return err
+		}
+	}
 	sourceStat, err := os.Lstat(source)
 	if os.IsNotExist(err) {
 		return errors.New("source does not exist")
+	} else if err != nil {
+		return err
+	}
+	switch c.Type {
+	case "symlink":
+	case "hard":
+		if sourceStat.IsDir() {
+			return errors.New("cannot create hard link to a directory")
+		}
+	default:
+		return errors.New("unknown link type '" + c.Type + "'")
+	}
+	switch c.Mode {
+	case "link":
+	case "copy":
+		return c.runCopy(source, path)
+	default:
+		return errors.New("unknown link mode '" + c.Mode + "'")
 	}
-	path := utils.ExpandUser(c.Path)
 	// check if link exists
 	pathStat, err := os.Lstat(path)
 	if err != nil && !os.IsNotExist(err) {
@@ -103,56 +171,57 @@ func (c LinkConfig) Run() error {
 			if err != nil {
 				return err
 			}
-			destStat, err := os.Lstat(dest)
+			// relative links are resolved from the directory containing the link
+			destPath := dest
+			if !filepath.IsAbs(dest) {
+				destPath = filepath.Join(filepath.Dir(path), dest)
+			}
+			destStat, err := os.Lstat(destPath)
 			if err != nil && !os.IsNotExist(err) {
 				return err // general stat error
 			}
 			// check link is already to correct dest
 			if os.SameFile(destStat, sourceStat) {
-				// link is correct
+				if c.Type == "symlink" && filepath.IsAbs(dest) != c.Relative {
+					// link is correct
+					linkLogger.TagDone("linked").Path(
+						emerald.HighlightPathStat(c.Path, pathStat),
+						emerald.HighlightPathStat(dest, destStat),
+					)
+					return nil
+				}
+				// link points to the source but is the wrong type so it is safe to replace
+				err := removeLink(path, source)
+				if err != nil {
+					return err
+				}
+				pathStat = nil
+			}
+		} else if os.SameFile(pathStat, sourceStat) {
+			if c.Type == "hard" {
+				// hard link is correct
 				linkLogger.TagDone("linked").Path(
 					emerald.HighlightPathStat(c.Path, pathStat),
-					emerald.HighlightPathStat(dest, destStat),
+					emerald.HighlightPathStat(source, sourceStat),
 				)
 				return nil
 			}
-		}
-		if c.Force || c.SafeForce {
-			if !utils.IsWritable(path) {
-				return os.ErrPermission
-			}
-			if c.Force {
-				if !store.DryRun {
-					err := os.Remove(path)
-					if err != nil {
-						return err
-					}
-				}
-				linkLogger.TagC(emerald.Red, "deleted").Println(emerald.HighlightPathStat(c.Path, pathStat))
-			} else {
-				for i := 1; i < 11; i++ {
-					dest := path + "." + strconv.Itoa(i)
-					if _, err := os.Lstat(dest); os.IsNotExist(err) {
-						if !store.DryRun {
-							err := os.Rename(path, dest)
-							if err != nil {
-								return err
-							}
-						}
-						linkLogger.TagC(emerald.Red, "renamed").Path(
-							emerald.HighlightPathStat(c.Path, pathStat),
-							emerald.HighlightPathStat(c.Path+"."+strconv.Itoa(i), pathStat),
-						)
-						break
-					}
-					if i == 10 {
-						return errors.New("unable to rename file: too many failed renames")
-					}
-				}
+			// hard link to the source is the wrong type so it is safe to replace
+			err := removeLink(path, source)
+			if err != nil {
+				return err
 			}
-		} else {
+			pathStat = nil
+		}
+	}
+	if pathStat != nil {
+		if !c.Force && !c.SafeForce {
 			return errors.New("failed to create link as target already exists")
 		}
+		err := c.removeTarget(path, pathStat)
+		if err != nil {
+			return err
+		}
 	}
 
 	// at this point the target does not exist
@@ -169,8 +238,41 @@ func (c LinkConfig) Run() error {
 	}
 
 	absSource, _ := filepath.Abs(source)
+	if c.Type == "hard" {
+		if !store.DryRun {
+			err := os.Link(absSource, path)
+			if err != nil {
+				return err
+			}
+			err = c.recordGlob()
+			if err != nil {
+				return err
+			}
+		}
+		linkLogger.TagSudo("linked").Path(
+			emerald.HighlightPathStat(c.Path, sourceStat),
+			emerald.HighlightPathStat(absSource, sourceStat),
+		)
+		return nil
+	}
+
+	target := absSource
+	if c.Relative {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		target, err = filepath.Rel(filepath.Dir(absPath), absSource)
+		if err != nil {
+			return err
+		}
+	}
 	if !store.DryRun {
-		err := os.Symlink(absSource, path)
+		err := os.Symlink(target, path)
+		if err != nil {
+			return err
+		}
+		err = c.recordGlob()
 		if err != nil {
 			return err
 		}
@@ -178,7 +280,440 @@ func (c LinkConfig) Run() error {
 
 	linkLogger.TagSudo("linked").Path(
 		emerald.HighlightPath(c.Path, os.ModeSymlink),
-		emerald.HighlightPathStat(absSource, sourceStat),
+		emerald.HighlightPathStat(target, sourceStat),
+	)
+	return nil
+}
+
+// removeLink deletes an existing link to the source, the source itself is kept
+func removeLink(path string, source string) error {
+	absPath, _ := filepath.Abs(path)
+	absSource, _ := filepath.Abs(source)
+	if absPath == absSource {
+		return errors.New("cannot link the source to itself")
+	}
+	if !utils.IsWritable(path) {
+		return os.ErrPermission
+	}
+	if store.DryRun {
+		return nil
+	}
+	return os.Remove(path)
+}
+
+// removeTarget deletes the existing file at path when force is set, otherwise
+// it is moved into the backup directory
+func (c LinkConfig) removeTarget(path string, pathStat os.FileInfo) error {
+	if !utils.IsWritable(path) {
+		return os.ErrPermission
+	}
+	if c.Force {
+		if !store.DryRun {
+			err := os.Remove(path)
+			if err != nil {
+				return err
+			}
+		}
+		linkLogger.TagC(emerald.Red, "deleted").Println(emerald.HighlightPathStat(c.Path, pathStat))
+		return nil
+	}
+	dest, err := backup.Save(path, "link")
+	if err != nil {
+		return err
+	}
+	linkLogger.TagC(emerald.Red, "backed up").Path(
+		emerald.HighlightPathStat(c.Path, pathStat),
+		emerald.HighlightPathStat(utils.ShrinkUser(dest), pathStat),
+	)
+	return nil
+}
+
+// adoptTarget moves an existing target into the dotfiles directory when the
+// source is missing or identical, otherwise the user is asked which to keep.
+// Returns true if linking should not continue.
+func (c LinkConfig) adoptTarget(source string, path string) (bool, error) {
+	pathStat, err := os.Lstat(path)
+	if err != nil || pathStat.Mode()&os.ModeSymlink != 0 || isSameFile(pathStat, source) {
+		// nothing to adopt
+		return false, nil
+	}
+	if !utils.IsWritable(path) {
+		return false, os.ErrPermission
+	}
+
+	if _, err := os.Lstat(source); err == nil {
+		sourceHash, err := utils.HashPath(source)
+		if err != nil {
+			return false, err
+		}
+		pathHash, err := utils.HashPath(path)
+		if err != nil {
+			return false, err
+		}
+		if sourceHash == pathHash {
+			if c.Mode == "copy" {
+				return false, nil
+			}
+			if !store.DryRun {
+				err := os.RemoveAll(path)
+				if err != nil {
+					return false, err
+				}
+			}
+			linkLogger.Tag("adopted").Path(
+				emerald.HighlightPath(c.Source, pathStat.Mode()),
+				emerald.HighlightPathStat(c.Path, pathStat),
+			)
+			return store.DryRun, nil
+		}
+
+		emerald.Print(utils.DiffFiles(source, path))
+		if store.DryRun {
+			linkLogger.TagC(emerald.Yellow, "differs").Path(
+				emerald.HighlightPath(c.Source, pathStat.Mode()),
+				emerald.HighlightPathStat(c.Path, pathStat),
+			)
+			return true, nil
+		}
+		choice, err := utils.Select(
+			"'"+c.Path+"' differs from '"+c.Source+"'",
+			[]string{"keep dotfiles version", "adopt existing version", "skip"},
+		)
+		if errors.Is(err, utils.ErrNonInteractive) {
+			// links created as root are run with the config piped to stdin
+			return true, errors.New("cannot adopt as target differs from source and stdin is not a terminal")
+		} else if err != nil {
+			return false, err
+		}
+		switch choice {
+		case 0:
+			dest, err := backup.Save(path, "adopt")
+			if err != nil {
+				return false, err
+			}
+			linkLogger.TagC(emerald.Red, "backed up").Path(
+				emerald.HighlightPathStat(c.Path, pathStat),
+				emerald.HighlightPathStat(utils.ShrinkUser(dest), pathStat),
+			)
+			return store.DryRun, nil
+		case 2:
+			return true, errors.New("skipped adopting as target differs from source")
+		}
+		if _, err := backup.Save(source, "adopt"); err != nil {
+			return false, err
+		}
+	}
+
+	if !store.DryRun {
+		err := os.MkdirAll(filepath.Dir(source), os.ModePerm)
+		if err != nil {
+			return false, err
+		}
+		if c.Mode == "copy" {
+			err = utils.CopyPath(path, source)
+		} else {
+			err = utils.MovePath(path, source)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	linkLogger.Tag("adopted").Path(
+		emerald.HighlightPath(c.Source, pathStat.Mode()),
+		emerald.HighlightPathStat(c.Path, pathStat),
+	)
+	return store.DryRun, nil
+}
+
+// runCopy deploys a copy of the source and uses the recorded hash of the
+// last deployment to determine whether the source, the copy or both changed
+func (c LinkConfig) runCopy(source string, path string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	key := "copy:" + absPath
+	recorded := store.Manifest.Get(key)
+
+	sourceHash, err := utils.HashPath(source)
+	if err != nil {
+		return err
+	}
+	pathStat, err := os.Lstat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if pathStat == nil {
+		if c.Mkdirs {
+			err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+			if err != nil {
+				return err
+			}
+		}
+		if !utils.IsWritable(filepath.Dir(path)) {
+			return os.ErrPermission
+		}
+		return c.deployCopy(source, path, key, sourceHash, "copied")
+	}
+
+	// replace links from previously running in link mode
+	if pathStat.Mode()&os.ModeSymlink != 0 {
+		if stat, err := os.Stat(path); err == nil && isSameFile(stat, source) {
+			if !utils.IsWritable(path) {
+				return os.ErrPermission
+			}
+			if !store.DryRun {
+				err := os.Remove(path)
+				if err != nil {
+					return err
+				}
+			}
+			return c.deployCopy(source, path, key, sourceHash, "copied")
+		}
+	}
+
+	pathHash, err := utils.HashPath(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case sourceHash == pathHash:
+		if recorded != sourceHash && !store.DryRun {
+			err := store.Manifest.SetSave(key, sourceHash)
+			if err != nil {
+				return err
+			}
+		}
+		linkLogger.TagDone("copied").Path(
+			emerald.HighlightPathStat(c.Path, pathStat),
+			emerald.HighlightPathStat(source),
+		)
+		return nil
+	case pathHash == recorded:
+		// only the source changed
+		return c.replaceCopy(source, path, key, sourceHash, pathStat, "updated")
+	case sourceHash == recorded:
+		// only the deployed copy changed
+		if !c.Pull {
+			linkLogger.TagC(emerald.Red, "modified").Path(
+				emerald.HighlightPathStat(c.Path, pathStat),
+				emerald.HighlightPathStat(source),
+			)
+			return errors.New("deployed copy has been modified, set pull to copy it into the dotfiles directory")
+		}
+		if !store.DryRun {
+			err := os.RemoveAll(source)
+			if err != nil {
+				return err
+			}
+			err = utils.CopyPath(path, source)
+			if err != nil {
+				return err
+			}
+			err = store.Manifest.SetSave(key, pathHash)
+			if err != nil {
+				return err
+			}
+		}
+		linkLogger.Tag("pulled").Path(
+			emerald.HighlightPathStat(source),
+			emerald.HighlightPathStat(c.Path, pathStat),
+		)
+		return nil
+	}
+
+	// both have changed or the target was not deployed by us
+	if c.Force {
+		return c.replaceCopy(source, path, key, sourceHash, pathStat, "replaced")
+	}
+	if c.SafeForce {
+		err := c.removeTarget(path, pathStat)
+		if err != nil {
+			return err
+		}
+		return c.deployCopy(source, path, key, sourceHash, "copied")
+	}
+	linkLogger.TagC(emerald.Red, "conflict").Path(
+		emerald.HighlightPathStat(c.Path, pathStat),
+		emerald.HighlightPathStat(source),
+	)
+	if recorded == "" {
+		return errors.New("failed to copy as target already exists")
+	}
+	return errors.New("both the source and deployed copy have been modified")
+}
+
+func (c LinkConfig) replaceCopy(source, path, key, hash string, pathStat os.FileInfo, tag string) error {
+	if !utils.IsWritable(path) {
+		return os.ErrPermission
+	}
+	if !store.DryRun {
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+	return c.deployCopy(source, path, key, hash, tag)
+}
+
+func (c LinkConfig) deployCopy(source, path, key, hash string, tag string) error {
+	if !store.DryRun {
+		err := utils.CopyPath(source, path)
+		if err != nil {
+			return err
+		}
+		err = store.Manifest.SetSave(key, hash)
+		if err != nil {
+			return err
+		}
+	}
+	linkLogger.TagSudo(tag).Path(
+		emerald.HighlightPath(c.Path, 0),
+		emerald.HighlightPathStat(source),
 	)
 	return nil
 }
+
+func isSameFile(stat os.FileInfo, path string) bool {
+	pathStat, err := os.Stat(path)
+	return err == nil && os.SameFile(stat, pathStat)
+}
+
+// expandGlob returns a config for each file matched by a glob source, the path
+// is used as the directory to create links in, other configs are returned as-is
+func (c *LinkConfig) expandGlob() ([]*LinkConfig, error) {
+	source := c.Source
+	dir := c.Path
+	err := template.RenderField(&source, &dir)
+	if err != nil {
+		return nil, err
+	}
+	if isConstantMatch(source) {
+		return []*LinkConfig{c}, nil
+	}
+
+	source, err = filepath.Abs(utils.ExpandUser(source))
+	if err != nil {
+		return nil, err
+	}
+	base, pattern := doublestar.SplitPattern(filepath.ToSlash(source))
+	matches, err := doublestar.Glob(os.DirFS(base), pattern)
+	if err != nil {
+		return nil, err
+	}
+	record, err := json.Marshal(linkGlob{Base: base, Pattern: pattern, Exclude: c.Exclude})
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make([]*LinkConfig, 0, len(matches))
+	changed := false
+
+	// update existing records to ensure changes to exclusions are reflected
+	if absDir, err := filepath.Abs(utils.ExpandUser(dir)); err == nil && !store.DryRun {
+		prefix := "link_glob:" + absDir + string(filepath.Separator)
+		for _, key := range store.Manifest.Keys() {
+			if !strings.HasPrefix(key, prefix) || store.Manifest.Get(key) == string(record) {
+				continue
+			}
+			var existing linkGlob
+			err := json.Unmarshal([]byte(store.Manifest.Get(key)), &existing)
+			if err == nil && existing.Base == base && existing.Pattern == pattern {
+				store.Manifest.Set(key, string(record))
+				changed = true
+			}
+		}
+	}
+
+	for _, match := range matches {
+		if isExcluded(c.Exclude, match) {
+			continue
+		}
+		name := match
+		if c.StripExt {
+			name = strings.TrimSuffix(name, path.Ext(name))
+		}
+		parent, file := path.Split(name)
+		name = parent + c.Prefix + file
+
+		config := *c
+		config.Source = filepath.Join(filepath.FromSlash(base), filepath.FromSlash(match))
+		config.Path = filepath.Join(dir, filepath.FromSlash(name))
+		config.Prefix = ""
+		config.StripExt = false
+		config.Exclude = nil
+		config.glob = string(record)
+		configs = append(configs, &config)
+	}
+	if changed {
+		err := store.Save()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return configs, nil
+}
+
+// recordGlob records the glob source of a link that was created
+// so that it can be cleaned once the source no longer matches
+func (c LinkConfig) recordGlob() error {
+	if c.glob == "" || store.DryRun {
+		return nil
+	}
+	key, err := c.globKey()
+	if err != nil {
+		return err
+	}
+	if store.Manifest.Get(key) == c.glob {
+		return nil
+	}
+	return store.Manifest.SetSave(key, c.glob)
+}
+
+// globKey returns the manifest key recording the glob source of the link
+func (c LinkConfig) globKey() (string, error) {
+	absPath, err := filepath.Abs(utils.ExpandUser(c.Path))
+	if err != nil {
+		return "", err
+	}
+	return "link_glob:" + absPath, nil
+}
+
+// isExcluded returns true if the path or its base name matches any of the patterns
+func isExcluded(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := doublestar.Match(pattern, name); matched {
+			return true
+		}
+		if matched, _ := doublestar.Match(pattern, path.Base(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// isStaleGlobLink returns true if the link was created from a glob source
+// that no longer matches the file it links to
+func isStaleGlobLink(link string, dest string) bool {
+	absLink, err := filepath.Abs(link)
+	if err != nil {
+		return false
+	}
+	value, present := store.Manifest.HasGet("link_glob:" + absLink)
+	if !present {
+		return false
+	}
+	var record linkGlob
+	if err := json.Unmarshal([]byte(value), &record); err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(record.Base), dest)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	matched, _ := doublestar.Match(record.Pattern, rel)
+	return !matched || isExcluded(record.Exclude, rel)
+}
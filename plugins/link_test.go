@@ -0,0 +1,142 @@
+package plugins
+
+import (
+	"github.com/jcwillox/dotbot/store"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withDryRun enables dry run mode for the duration of a test
+func withDryRun(t *testing.T) {
+	dryRun := store.DryRun
+	store.DryRun = true
+	t.Cleanup(func() {
+		store.DryRun = dryRun
+	})
+}
+
+func TestGlobLinkMarshal(t *testing.T) {
+	withDryRun(t)
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	target := t.TempDir()
+	config := &LinkConfig{Path: target, Source: filepath.Join(dir, "*"), Type: "symlink", Mode: "link"}
+	configs, err := config.expandGlob()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 links but got %d", len(configs))
+	}
+	for _, config := range configs {
+		// configs are marshalled when they are passed to dotbot under sudo
+		data, err := yaml.Marshal([]map[string]interface{}{{"link": &config}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded PluginList
+		if err := yaml.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := (*decoded[0]["link"].(*LinkBase))[0].Path; got != config.Path {
+			t.Errorf("marshalled path = %q, want %q", got, config.Path)
+		}
+		key, err := config.globKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "link_glob:" + filepath.Join(target, filepath.Base(config.Source))
+		if key != want {
+			t.Errorf("glob key after marshalling = %q, want %q", key, want)
+		}
+	}
+}
+
+func TestLinkReplaceType(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing func(source, path string) error
+		linkType string
+		wantErr  string
+	}{
+		{"hard to symlink", os.Link, "symlink", ""},
+		{"symlink to hard", os.Symlink, "hard", ""},
+		{"unrelated file to symlink", writeFile, "symlink", "failed to create link as target already exists"},
+		{"unrelated file to hard", writeFile, "hard", "failed to create link as target already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "source")
+			path := filepath.Join(dir, "path")
+			if err := os.WriteFile(source, []byte("source"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.existing(source, path); err != nil {
+				t.Fatal(err)
+			}
+			config := LinkConfig{Path: path, Source: source, Mkdirs: true, Type: tt.linkType, Mode: "link"}
+			err := config.Run()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			pathStat, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if isSymlink := pathStat.Mode()&os.ModeSymlink != 0; isSymlink != (tt.linkType == "symlink") {
+				t.Errorf("target is symlink = %v, want a %s link", isSymlink, tt.linkType)
+			}
+			if !os.SameFile(mustStat(t, path), mustStat(t, source)) {
+				t.Errorf("target does not link to the source")
+			}
+			if data, err := os.ReadFile(source); err != nil || string(data) != "source" {
+				t.Errorf("source was modified: %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func TestLinkToItself(t *testing.T) {
+	for _, linkType := range []string{"symlink", "hard"} {
+		t.Run(linkType, func(t *testing.T) {
+			source := filepath.Join(t.TempDir(), "source")
+			if err := os.WriteFile(source, []byte("source"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			// a hard link to itself is already correct, but must never be replaced
+			config := LinkConfig{Path: source, Source: source, Type: linkType, Mode: "link"}
+			if err := config.Run(); linkType == "symlink" && (err == nil || err.Error() != "cannot link the source to itself") {
+				t.Errorf("Run() error = %v, want cannot link the source to itself", err)
+			}
+			if data, err := os.ReadFile(source); err != nil || string(data) != "source" {
+				t.Errorf("source was modified: %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func writeFile(_, path string) error {
+	return os.WriteFile(path, []byte("other"), 0644)
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stat
+}
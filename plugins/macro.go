@@ -0,0 +1,190 @@
+package plugins
+
+import (
+	"fmt"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/yamltools"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strings"
+)
+
+// Macros are named blocks of directives that are expanded in place of
+// `use` directives when the directive lists of the config are unmarshalled
+type Macros map[string]*MacroConfig
+type MacroConfig struct {
+	Params []MacroParam
+	Config *yaml.Node
+}
+type MacroParam struct {
+	Name string
+	// Default is nil when the parameter is required
+	Default *yaml.Node
+}
+
+type macroUse struct {
+	Macro string
+	With  map[string]yaml.Node
+}
+
+// paramRegex matches parameter references e.g. ${{ name }}
+var paramRegex = regexp.MustCompile(`\$\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*}}`)
+
+func (c *MacroConfig) UnmarshalYAML(n *yaml.Node) error {
+	// macros without parameters can be written as just a list of directives
+	if n.Kind == yaml.SequenceNode {
+		c.Config = n
+		return nil
+	}
+	var raw struct {
+		Params yaml.Node
+		Config yaml.Node
+	}
+	err := n.Decode(&raw)
+	if err != nil {
+		return err
+	}
+	if raw.Config.Kind == 0 {
+		return fmt.Errorf("line %d: macro is missing config", n.Line)
+	}
+	c.Config = &raw.Config
+	switch raw.Params.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(raw.Params.Content); i += 2 {
+			param := MacroParam{Name: raw.Params.Content[i].Value}
+			if value := raw.Params.Content[i+1]; value.Tag != "!!null" {
+				param.Default = value
+			}
+			c.Params = append(c.Params, param)
+		}
+	case yaml.SequenceNode:
+		for _, name := range raw.Params.Content {
+			c.Params = append(c.Params, MacroParam{Name: name.Value})
+		}
+	}
+	return nil
+}
+
+// activeMacros are the macros of the config being unmarshalled and macroStack
+// the macros being expanded, they are used when unmarshalling directive lists
+var (
+	activeMacros Macros
+	macroStack   []string
+)
+
+// macroItem is a directive and the macros it was expanded from
+type macroItem struct {
+	node  *yaml.Node
+	stack []string
+}
+
+// expandList replaces `use` directives in a list of directives with the body
+// of their macro, only directives are expanded so that values such as vars
+// which happen to contain a use key are left alone
+func (m Macros) expandList(items []*yaml.Node, stack []string) ([]macroItem, error) {
+	expanded := make([]macroItem, 0, len(items))
+	for _, item := range items {
+		use := getUseNode(item)
+		if use == nil {
+			expanded = append(expanded, macroItem{item, stack})
+			continue
+		}
+		name, body, err := m.instantiate(use, stack)
+		if err != nil {
+			return nil, err
+		}
+		nested, err := m.expandList(body.Content, append(stack[:len(stack):len(stack)], name))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, nested...)
+	}
+	return expanded, nil
+}
+
+// getUseNode returns the value of a `use` directive or nil if n is not one
+func getUseNode(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.MappingNode && len(n.Content) == 2 && n.Content[0].Value == "use" {
+		return n.Content[1]
+	}
+	return nil
+}
+
+// instantiate returns the name of the macro and a sequence node containing
+// its body with all parameters substituted
+func (m Macros) instantiate(n *yaml.Node, stack []string) (string, *yaml.Node, error) {
+	var use macroUse
+	err := yamltools.ScalarToMapVal(n, "macro").Decode(&use)
+	if err != nil {
+		return "", nil, err
+	}
+	macro, present := m[use.Macro]
+	if !present {
+		return "", nil, fmt.Errorf("line %d: unknown macro '%s'", n.Line, use.Macro)
+	}
+	if utils.ArrContains(stack, use.Macro) {
+		return "", nil, fmt.Errorf(
+			"line %d: macro '%s' is used recursively (%s)",
+			n.Line, use.Macro, strings.Join(append(stack, use.Macro), " -> "),
+		)
+	}
+
+	args := make(map[string]*yaml.Node, len(macro.Params))
+	for _, param := range macro.Params {
+		if value, present := use.With[param.Name]; present {
+			args[param.Name] = &value
+		} else if param.Default != nil {
+			args[param.Name] = param.Default
+		} else {
+			return "", nil, fmt.Errorf("line %d: macro '%s' requires parameter '%s'", n.Line, use.Macro, param.Name)
+		}
+	}
+	for name := range use.With {
+		if _, present := args[name]; !present {
+			return "", nil, fmt.Errorf("line %d: macro '%s' has no parameter '%s'", n.Line, use.Macro, name)
+		}
+	}
+
+	body := yamltools.EnsureList(yamltools.CopyNode(macro.Config))
+	err = substituteParams(body, args, use.Macro)
+	if err != nil {
+		return "", nil, err
+	}
+	return use.Macro, body, nil
+}
+
+// substituteParams replaces parameter references in all scalars of n, a scalar
+// that consists of only a reference is replaced by the argument node itself
+func substituteParams(n *yaml.Node, args map[string]*yaml.Node, macro string) error {
+	if n.Kind == yaml.ScalarNode {
+		if match := paramRegex.FindStringSubmatch(n.Value); match != nil && match[0] == n.Value {
+			arg, present := args[match[1]]
+			if !present {
+				return fmt.Errorf("line %d: macro '%s' has no parameter '%s'", n.Line, macro, match[1])
+			}
+			*n = *yamltools.CopyNode(arg)
+			return nil
+		}
+		var err error
+		n.Value = paramRegex.ReplaceAllStringFunc(n.Value, func(s string) string {
+			name := paramRegex.FindStringSubmatch(s)[1]
+			arg, present := args[name]
+			if !present {
+				err = fmt.Errorf("line %d: macro '%s' has no parameter '%s'", n.Line, macro, name)
+			} else if arg.Kind != yaml.ScalarNode {
+				err = fmt.Errorf("line %d: parameter '%s' must be a scalar when used within a string", n.Line, name)
+			} else {
+				return arg.Value
+			}
+			return s
+		})
+		return err
+	}
+	for _, child := range n.Content {
+		err := substituteParams(child, args, macro)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package plugins
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMacroExpansion(t *testing.T) {
+	config, err := FromBytes([]byte(`
+macros:
+  hello:
+    params: {who: world}
+    config:
+      - shell: echo "hello ${{ who }}"
+  twice:
+    - use: {macro: hello, with: {who: first}}
+    - if:
+        expr: "true"
+        then:
+          use: hello
+vars:
+  literal:
+    use: hello
+config:
+  - use: twice
+  - for_each:
+      items:
+        - use: hello
+      do: []
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Config) != 3 {
+		t.Fatalf("expected 3 directives after expansion but got %d", len(config.Config))
+	}
+	shell := config.Config[0]["shell"].(*ShellBase)
+	if got := (*shell)[0].Command.Command; got != `echo "hello first"` {
+		t.Errorf("expanded command = %q", got)
+	}
+	then := (*config.Config[1]["if"].(*IfBase))[0].Then
+	if got := (*then[0]["shell"].(*ShellBase))[0].Command.Command; got != `echo "hello world"` {
+		t.Errorf("expanded nested command = %q", got)
+	}
+	// values which are not directives are left alone
+	if literal := config.Vars["literal"].(map[string]interface{}); literal["use"] != "hello" {
+		t.Errorf("vars were expanded: %v", literal)
+	}
+	items := (*config.Config[2]["for_each"].(*ForEachBase))[0].Items.([]interface{})
+	if item := items[0].(map[string]interface{}); item["use"] != "hello" {
+		t.Errorf("for_each items were expanded: %v", items)
+	}
+}
+
+func TestMacroRecursion(t *testing.T) {
+	_, err := FromBytes([]byte(`
+macros:
+  loop:
+    - if:
+        expr: "true"
+        then:
+          - use: loop
+config:
+  - use: loop
+`))
+	if err == nil || !strings.Contains(err.Error(), "macro 'loop' is used recursively (loop -> loop)") {
+		t.Errorf("expected recursion error but got %v", err)
+	}
+}
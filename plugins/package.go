@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
-	"fmt"
 	"io"
 
 	"github.com/jcwillox/dotbot/log"
@@ -60,7 +59,7 @@ func (b PackageBase) RunAll() error {
 	for _, config := range b {
 		err := config.Run()
 		if err != nil {
-			fmt.Println("ERROR:", err)
+			reportError(err)
 		}
 	}
 	return nil
@@ -2,13 +2,16 @@ package plugins
 
 import (
 	"fmt"
+	"github.com/jcwillox/dotbot/backup"
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/template"
 	"github.com/jcwillox/dotbot/utils"
 	"github.com/jcwillox/dotbot/yamltools"
 	"github.com/jcwillox/emerald"
 	"gopkg.in/yaml.v3"
 	"os"
+	"sort"
 	"time"
 )
 
@@ -21,6 +24,8 @@ type Config struct {
 	ShowTotalTime  *bool              `yaml:"show_total_time"`
 	StripPath      StripPathBase      `yaml:"strip_path"`
 	Vars           map[string]interface{}
+	Templates      map[string]string
+	Macros         Macros `yaml:"-"`
 }
 
 func (c *Config) UnmarshalYAML(n *yaml.Node) error {
@@ -33,6 +38,15 @@ func (c *Config) UnmarshalYAML(n *yaml.Node) error {
 		return err
 	}
 	n = yamltools.ListToMapVal(n, "config")
+	if macros := yamltools.MapValue(n, "macros"); macros != nil {
+		err = macros.Decode(&c.Macros)
+		if err != nil {
+			return err
+		}
+	}
+	prev := activeMacros
+	activeMacros = c.Macros
+	defer func() { activeMacros = prev }()
 	type ConfigT Config
 	return n.Decode((*ConfigT)(c))
 }
@@ -44,33 +58,62 @@ type Plugin interface {
 	RunAll() error
 }
 
+type Directive struct {
+	Name string `json:"name"`
+	Desc string `json:"description"`
+	new  func() Plugin
+}
+
+var directives = []Directive{
+	{"clean", "Remove dead links that point into the dotfiles directory", func() Plugin { return &CleanBase{} }},
+	{"create", "Create directories with the given mode", func() Plugin { return &CreateBase{} }},
+	{"download", "Download files and optionally extract them", func() Plugin { return &DownloadBase{} }},
+	{"extract", "Extract files from an archive", func() Plugin { return &ExtractBase{} }},
+	{"for_each", "Run directives for each item in a list or map", func() Plugin { return &ForEachBase{} }},
+	{"git", "Clone or pull git repositories", func() Plugin { return &GitBase{} }},
+	{"github_release", "Install the best matching asset of the latest GitHub release", func() Plugin { return &GithubReleaseBase{} }},
+	{"group", "Named group of directives that can be selected by profiles", func() Plugin { return &GroupBase{} }},
+	{"if", "Run directives when all template conditions are true", func() Plugin { return &IfBase{} }},
+	{"install", "Install and update tools when a new version is available", func() Plugin { return &InstallBase{} }},
+	{"link", "Symlink files from the dotfiles directory", func() Plugin { return &LinkBase{} }},
+	{"package", "Install packages using the system package manager", func() Plugin { return &PackageBase{} }},
+	{"prompt", "Ask for values which differ per machine and save the answers", func() Plugin { return &PromptBase{} }},
+	{"sharkdp", "Install a tool released by github.com/sharkdp", func() Plugin { return &SharkdpBase{} }},
+	{"shell", "Run shell commands", func() Plugin { return &ShellBase{} }},
+	{"system", "Run directives on the first matching system", func() Plugin { return &SystemBase{} }},
+	{"template", "Render files from templates into place", func() Plugin { return &TemplateBase{} }},
+	{"vars", "Add variables to the template namespace", func() Plugin { return &VarsBase{} }},
+	// use is replaced by the body of the macro when the config is unmarshalled
+	{"use", "Expand a macro defined under macros in place of the directive", nil},
+}
+
+// Directives returns all registered directives sorted by name
+func Directives() []Directive {
+	sorted := append([]Directive{}, directives...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
 func getDirective(key string) Plugin {
-	plugin, present := map[string]Plugin{
-		"clean":    &CleanBase{},
-		"create":   &CreateBase{},
-		"download": &DownloadBase{},
-		"extract":  &ExtractBase{},
-		"git":      &GitBase{},
-		"group":    &GroupBase{},
-		"if":       &IfBase{},
-		"install":  &InstallBase{},
-		"link":     &LinkBase{},
-		"package":  &PackageBase{},
-		"sharkdp":  &SharkdpBase{},
-		"shell":    &ShellBase{},
-		"system":   &SystemBase{},
-		"vars":     &VarsBase{},
-	}[key]
-	if !present {
-		return nil
-	}
-	return plugin
+	for _, directive := range directives {
+		if directive.Name == key && directive.new != nil {
+			return directive.new()
+		}
+	}
+	return nil
 }
 
 func (c *PluginList) UnmarshalYAML(n *yaml.Node) error {
 	n = yamltools.EnsureList(n)
-	*c = make(PluginList, len(n.Content))
-	for i, node := range n.Content {
+	items, err := activeMacros.expandList(n.Content, macroStack)
+	if err != nil {
+		return err
+	}
+	*c = make(PluginList, len(items))
+	for i, item := range items {
+		node := item.node
 		// range over keys
 		keys := yamltools.MapKeys(node)
 		// lookup concrete type
@@ -79,8 +122,11 @@ func (c *PluginList) UnmarshalYAML(n *yaml.Node) error {
 			log.Warnf("skipping unknown directive '%s'\n", keys[0])
 			continue
 		}
-		// decode into type
+		// decode into type, nested lists are expanded within the macro
+		prev := macroStack
+		macroStack = item.stack
 		err := node.Content[1].Decode(plugin)
+		macroStack = prev
 		if err != nil {
 			return err
 		}
@@ -107,9 +153,41 @@ func FromBytes(data []byte) (Config, error) {
 	return config, err
 }
 
+// Merge adds the directives, profiles, vars and templates of a config from
+// another source, values from the other config take precedence. Macros are
+// not merged as they are already expanded when the config is unmarshalled
+func (c *Config) Merge(other Config) {
+	c.Config = append(c.Config, other.Config...)
+	// the first matching profile is used so those of the other config go first
+	c.Profiles = append(append(ProfilesBase{}, other.Profiles...), c.Profiles...)
+	c.DefaultProfile = append(append(DefaultProfileBase{}, other.DefaultProfile...), c.DefaultProfile...)
+	if c.Vars == nil {
+		c.Vars = make(map[string]interface{}, len(other.Vars))
+	}
+	for key, value := range other.Vars {
+		c.Vars[key] = value
+	}
+	if c.Templates == nil {
+		c.Templates = make(map[string]string, len(other.Templates))
+	}
+	for name, tmpl := range other.Templates {
+		c.Templates[name] = tmpl
+	}
+}
+
+// Share replaces the vars, templates and profiles with those merged from
+// all sources, so that they apply to the directives of every source
+func (c *Config) Share(merged Config) {
+	c.Vars = merged.Vars
+	c.Templates = merged.Templates
+	c.Profiles = merged.Profiles
+	c.DefaultProfile = merged.DefaultProfile
+}
+
 // RunAll runs all configs returns true if the config should be reloaded
 func (c Config) RunAll(useBasic ...bool) bool {
 	store.TmplVars(c.Vars)
+	template.Define(c.Templates)
 	c.StripPath.Run()
 
 	if useBasic == nil {
@@ -146,10 +224,47 @@ func (c Config) RunAll(useBasic ...bool) bool {
 		c.Config.RunAll()
 	}
 
+	if removed, err := backup.AutoPrune(); err != nil {
+		log.Warnln("failed to prune old backups", err)
+	} else if len(removed) > 0 {
+		log.Debugln("pruned", len(removed), "old backups")
+	}
 	return false
 }
 
-func (c PluginList) RunAll() {
+// failedTasks counts the directives which failed, including those that are
+// only reported, so that failures within nested directives can be detected
+var failedTasks int
+
+// FailedTasks returns the number of directives which have failed
+func FailedTasks() int {
+	return failedTasks
+}
+
+// reportError prints the error of a failed directive and counts it as failed
+func reportError(err error) {
+	fmt.Println("ERROR:", err)
+	taskFailed()
+}
+
+// taskFailed counts a failed directive which has printed its own error
+func taskFailed() {
+	failedTasks++
+}
+
+// runNested runs a nested list of directives returning an error if any
+// failed, including directives such as shell which only report their errors
+func runNested(list PluginList) error {
+	failed := failedTasks
+	err := list.RunAll()
+	if err == nil && failedTasks > failed {
+		err = fmt.Errorf("%d tasks failed", failedTasks-failed)
+	}
+	return err
+}
+
+// RunAll runs each enabled directive, returning an error if any of them failed
+func (c PluginList) RunAll() error {
 	errorCount := 0
 	for _, item := range c {
 		for _, plugin := range item {
@@ -161,8 +276,65 @@ func (c PluginList) RunAll() {
 			}
 		}
 	}
+	store.RemoveTempFiles()
 	if errorCount > 0 {
-		fmt.Printf("ERROR: %d tasks failed out of %d\n", errorCount, len(c))
+		err := fmt.Errorf("%d tasks failed out of %d", errorCount, len(c))
+		fmt.Println("ERROR:", err)
+		return err
 	}
-	store.RemoveTempFiles()
+	return nil
+}
+
+// Len returns the number of directives in the list, excluding unknown directives
+func (c PluginList) Len() int {
+	count := 0
+	for _, item := range c {
+		count += len(item)
+	}
+	return count
+}
+
+// Walk calls fn for every directive in the list, including directives
+// nested inside other directives such as groups and conditionals
+func (c PluginList) Walk(fn func(key string, plugin Plugin)) {
+	for _, item := range c {
+		for key, plugin := range item {
+			fn(key, plugin)
+			for _, list := range nestedLists(plugin) {
+				list.Walk(fn)
+			}
+		}
+	}
+}
+
+// nestedLists returns the plugin lists directly contained in a directive
+func nestedLists(plugin Plugin) []PluginList {
+	lists := make([]PluginList, 0, 2)
+	switch b := plugin.(type) {
+	case *GroupBase:
+		for _, c := range *b {
+			lists = append(lists, c.Config)
+		}
+	case *IfBase:
+		for _, c := range *b {
+			lists = append(lists, c.Then, c.Else)
+		}
+	case *SystemBase:
+		for _, c := range *b {
+			lists = append(lists, c.Then)
+		}
+	case *ForEachBase:
+		for _, c := range *b {
+			lists = append(lists, c.Do)
+		}
+	case *InstallBase:
+		for _, c := range *b {
+			lists = append(lists, c.Then)
+		}
+	case *GithubReleaseBase:
+		for _, c := range *b {
+			lists = append(lists, c.Then)
+		}
+	}
+	return lists
 }
@@ -34,6 +34,7 @@ type DefaultProfileBase []DefaultProfileConfig
 type DefaultProfileConfig struct {
 	Profile  string
 	Template string
+	Expr     *Expression `yaml:",omitempty"`
 }
 
 func (b *DefaultProfileBase) UnmarshalYAML(n *yaml.Node) error {
@@ -45,7 +46,11 @@ func (b *DefaultProfileBase) UnmarshalYAML(n *yaml.Node) error {
 
 func (c *DefaultProfileConfig) UnmarshalYAML(n *yaml.Node) error {
 	n = yamltools.ScalarToMap(n)
-	n = yamltools.MapSplitKeyVal(n, "profile", "template")
+	if yamltools.IsScalarMap(n) {
+		n = yamltools.MapSplitKeyVal(n, "profile", "template")
+	} else {
+		n = yamltools.MapKeyIntoValueMap(n, "profile")
+	}
 	type DefaultProfileConfigT DefaultProfileConfig
 	return n.Decode((*DefaultProfileConfigT)(c))
 }
@@ -69,19 +74,35 @@ func (b ProfilesBase) GetGroups(profile string) []string {
 }
 
 func (b DefaultProfileBase) GetDefaultProfile() string {
+	config, _ := b.Match()
+	return config.Profile
+}
+
+// Match returns the first entry whose template renders true and expression
+// is true on this machine, entries without either always match
+func (b DefaultProfileBase) Match() (DefaultProfileConfig, bool) {
 	for _, config := range b {
+		if config.Expr != nil {
+			result, err := config.Expr.Eval()
+			if err != nil {
+				log.Fatalln("Failed to evaluate profile expression", err)
+			}
+			if !result {
+				continue
+			}
+		}
 		if config.Template == "" {
-			return config.Profile
+			return config, true
 		}
 		result, err := template.Parse(config.Template).RenderTrue()
 		if err != nil {
 			log.Fatalln("Failed to render profile template", err)
 		}
 		if result {
-			return config.Profile
+			return config, true
 		}
 	}
-	return ""
+	return DefaultProfileConfig{}, false
 }
 
 func LogProfile(name string) {
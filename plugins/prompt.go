@@ -0,0 +1,117 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"github.com/creasty/defaults"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/template"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/yamltools"
+	"github.com/jcwillox/emerald"
+	"gopkg.in/yaml.v3"
+	"strings"
+)
+
+var promptLogger = log.NewBasicLogger("PROMPT")
+
+type PromptBase []*PromptConfig
+type PromptConfig struct {
+	Name    string   `yaml:",omitempty"`
+	Message string   `yaml:",omitempty"`
+	Type    string   `default:"text"`
+	Choices []string `yaml:",omitempty"`
+	Default *string  `yaml:",omitempty"`
+}
+
+func (b *PromptBase) UnmarshalYAML(n *yaml.Node) error {
+	n = yamltools.MapToSliceMap(n)
+	n = yamltools.EnsureList(n)
+	type PromptBaseT PromptBase
+	return n.Decode((*PromptBaseT)(b))
+}
+
+func (c *PromptConfig) UnmarshalYAML(n *yaml.Node) error {
+	defaults.MustSet(c)
+	n = yamltools.ScalarToMap(n)
+	if yamltools.IsScalarMap(n) {
+		n = yamltools.MapSplitKeyVal(n, "name", "message")
+	} else {
+		n = yamltools.MapKeyIntoValueMap(n, "name")
+	}
+	type PromptConfigT PromptConfig
+	err := n.Decode((*PromptConfigT)(c))
+	if err != nil {
+		return err
+	}
+	if len(c.Choices) > 0 && c.Type == utils.PromptText {
+		c.Type = utils.PromptChoice
+	}
+	if !utils.ArrContains(utils.PromptTypes, c.Type) {
+		return fmt.Errorf("line %d: unknown prompt type '%s', expected one of %s", n.Line, c.Type, strings.Join(utils.PromptTypes, ", "))
+	}
+	if c.Type == utils.PromptChoice && len(c.Choices) == 0 {
+		return fmt.Errorf("line %d: prompt '%s' requires choices", n.Line, c.Name)
+	}
+	if c.Default != nil && c.Type == utils.PromptChoice && !utils.ArrContains(c.Choices, *c.Default) {
+		return fmt.Errorf("line %d: default '%s' is not one of the choices", n.Line, *c.Default)
+	}
+	return nil
+}
+
+func (c *PromptConfig) MarshalYAML() (interface{}, error) {
+	name := c.Name
+	c.Name = ""
+	type PromptConfigT PromptConfig
+	return map[string]*PromptConfigT{name: (*PromptConfigT)(c)}, nil
+}
+
+func (b PromptBase) Enabled() bool {
+	return true
+}
+
+func (b PromptBase) RunAll() error {
+	hasError := false
+	for _, config := range b {
+		err := config.Run()
+		if err != nil {
+			log.Errorln("Failed to answer prompt:", err)
+			taskFailed()
+			hasError = true
+		}
+	}
+	if hasError {
+		return errors.New("failed to answer some prompts")
+	}
+	return nil
+}
+
+func (c PromptConfig) Run() error {
+	message := c.Message
+	err := template.RenderField(&message)
+	if err != nil {
+		return err
+	}
+	_, saved := store.Prompts.HasGet(c.Name)
+	value, err := utils.Prompt{
+		Name:    c.Name,
+		Message: message,
+		Type:    c.Type,
+		Choices: c.Choices,
+		Default: c.Default,
+	}.Answer()
+	if err != nil {
+		return err
+	}
+	store.TmplVar(c.Name, value)
+	name := emerald.Cyan + c.Name + emerald.Reset
+	if saved {
+		promptLogger.TagDone("saved").Println(name)
+	} else if _, answered := store.Prompts.HasGet(c.Name); answered {
+		promptLogger.Tag("answered").Println(name)
+	} else {
+		promptLogger.Tag("default").Println(name)
+	}
+	return nil
+}
@@ -1,11 +1,10 @@
 package plugins
 
 import (
-	"fmt"
+	"github.com/jcwillox/dotbot/facts"
 	"github.com/jcwillox/dotbot/utils"
 	"github.com/jcwillox/dotbot/utils/sudo"
 	"github.com/jcwillox/dotbot/yamltools"
-	"github.com/shirou/gopsutil/host"
 	"gopkg.in/yaml.v3"
 	"runtime"
 )
@@ -27,7 +26,7 @@ func (b SharkdpBase) RunAll() error {
 	for _, config := range b {
 		err := config.Run()
 		if err != nil {
-			fmt.Println("ERROR:", err)
+			reportError(err)
 		}
 	}
 	return nil
@@ -36,11 +35,7 @@ func (b SharkdpBase) RunAll() error {
 func (c SharkdpConfig) Run() error {
 	name := string(c)
 	url := "https://github.com/sharkdp/" + name
-	_, family, _, err := host.PlatformInformation()
-	if err != nil {
-		return err
-	}
-	if family == "debian" && sudo.CanSudo() {
+	if facts.String("family") == "debian" && sudo.CanSudo() {
 		return InstallConfig{
 			Name: name,
 			Url:  url,
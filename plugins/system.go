@@ -1,12 +1,11 @@
 package plugins
 
 import (
-	"github.com/jcwillox/dotbot/template"
+	"fmt"
+	"github.com/jcwillox/dotbot/facts"
 	"github.com/jcwillox/dotbot/utils"
-	"github.com/jcwillox/dotbot/utils/sudo"
 	"github.com/jcwillox/dotbot/yamltools"
 	"gopkg.in/yaml.v3"
-	"runtime"
 )
 
 type SystemBase []SystemConfig
@@ -17,9 +16,17 @@ type SystemConfig struct {
 	Family   FlatList `yaml:",omitempty"`
 	Libc     FlatList `yaml:",omitempty"`
 	Distro   FlatList `yaml:",omitempty"`
-	IsRoot   bool     `yaml:"is_root"`
-	CanSudo  bool     `yaml:"can_sudo"`
-	Then     PluginList
+	// DistroID matches the ID from os-release e.g. ubuntu
+	DistroID FlatList `yaml:"distro_id,omitempty"`
+	// DistroLike matches the distro ID or any distro it is derived from
+	DistroLike FlatList `yaml:"distro_like,omitempty"`
+	// DistroVersion is a version constraint e.g. >=22.04
+	DistroVersion string `yaml:"distro_version,omitempty"`
+	IsRoot        bool   `yaml:"is_root"`
+	CanSudo       bool   `yaml:"can_sudo"`
+	// Facts matches any fact against a list of values
+	Facts map[string]FlatList `yaml:",omitempty"`
+	Then  PluginList
 }
 
 func (b *SystemBase) UnmarshalYAML(n *yaml.Node) error {
@@ -28,6 +35,26 @@ func (b *SystemBase) UnmarshalYAML(n *yaml.Node) error {
 	return n.Decode((*SystemBaseT)(b))
 }
 
+func (c *SystemConfig) UnmarshalYAML(n *yaml.Node) error {
+	type SystemConfigT SystemConfig
+	err := n.Decode((*SystemConfigT)(c))
+	if err != nil {
+		return err
+	}
+	if c.DistroVersion != "" {
+		_, err := utils.ParseConstraint(c.DistroVersion)
+		if err != nil {
+			return fmt.Errorf("line %d: %s", n.Line, err)
+		}
+	}
+	for name := range c.Facts {
+		if !facts.Exists(name) {
+			return fmt.Errorf("line %d: unknown fact '%s'", n.Line, name)
+		}
+	}
+	return nil
+}
+
 func (b SystemBase) Enabled() bool {
 	return true
 }
@@ -42,31 +69,45 @@ func (b SystemBase) RunAll() error {
 }
 
 func (c SystemConfig) Run() bool {
-	if c.OS != nil && !utils.ArrContains(c.OS, runtime.GOOS) {
+	if c.OS != nil && !utils.ArrContains(c.OS, facts.String("os")) {
 		return false
 	}
-	if c.Arch != nil && !utils.ArrContains(c.Arch, runtime.GOARCH) {
+	if c.Arch != nil && !utils.ArrContains(c.Arch, facts.String("arch")) {
 		return false
 	}
-	if c.Platform != nil || c.Family != nil {
-		platform, family := utils.GetPlatformInfo()
-		if c.Platform != nil && !utils.ArrContains(c.Platform, platform) {
-			return false
-		}
-		if c.Family != nil && !utils.ArrContains(c.Family, family) {
-			return false
-		}
+	if c.Platform != nil && !utils.ArrContains(c.Platform, facts.String("platform")) {
+		return false
 	}
-	if c.Libc != nil && !utils.ArrContains(c.Libc, utils.GetLibc()) {
+	if c.Family != nil && !utils.ArrContains(c.Family, facts.String("family")) {
 		return false
 	}
-	if c.Distro != nil && !utils.ArrContains(c.Distro, template.Distro()) {
+	if c.Libc != nil && !utils.ArrContains(c.Libc, facts.String("libc")) {
 		return false
 	}
-	if c.IsRoot && !sudo.IsRoot() {
+	if c.Distro != nil && !utils.ArrContains(c.Distro, facts.Distro()) {
+		return false
+	}
+	if c.DistroID != nil && !utils.ArrContains(c.DistroID, facts.String("distro_id")) {
+		return false
+	}
+	if c.DistroLike != nil && !facts.MatchDistroLike(c.DistroLike) {
+		return false
+	}
+	if c.DistroVersion != "" {
+		ok, err := utils.CheckConstraint(c.DistroVersion, facts.String("distro_version"))
+		if err != nil || !ok {
+			return false
+		}
+	}
+	for name, values := range c.Facts {
+		if !utils.ArrContains(values, facts.String(name)) {
+			return false
+		}
+	}
+	if c.IsRoot && !facts.Bool("is_root") {
 		return false
 	}
-	if !c.IsRoot && c.CanSudo && !sudo.CanSudo() {
+	if !c.IsRoot && c.CanSudo && !facts.Bool("can_sudo") {
 		return false
 	}
 	c.Then.RunAll()
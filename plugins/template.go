@@ -0,0 +1,247 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/creasty/defaults"
+	"github.com/jcwillox/dotbot/backup"
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/dotbot/template"
+	"github.com/jcwillox/dotbot/utils"
+	"github.com/jcwillox/dotbot/utils/sudo"
+	"github.com/jcwillox/dotbot/yamltools"
+	"github.com/jcwillox/emerald"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+var templateLogger = log.NewBasicLogger("TEMPLATE")
+
+type TemplateBase []*TemplateConfig
+type TemplateConfig struct {
+	Path   string `yaml:",omitempty"`
+	Source string
+	Mode   utils.WeakFileMode `default:"420"`
+	// Owner is either user or user:group
+	Owner  string `yaml:",omitempty"`
+	Mkdirs bool   `default:"true"`
+	// Delims are the left and right action delimiters e.g. ["[[", "]]"]
+	Delims []string `yaml:",omitempty"`
+}
+
+func (b *TemplateBase) UnmarshalYAML(n *yaml.Node) error {
+	n = yamltools.EnsureFlatList(n)
+	n = yamltools.MapToSliceMap(n)
+	type TemplateBaseT TemplateBase
+	return n.Decode((*TemplateBaseT)(b))
+}
+
+func (c *TemplateConfig) UnmarshalYAML(n *yaml.Node) error {
+	defaults.MustSet(c)
+	if yamltools.IsScalarMap(n) {
+		n = yamltools.MapSplitKeyVal(n, "path", "source")
+	} else {
+		n = yamltools.MapKeyIntoValueMap(n, "path")
+	}
+	type TemplateConfigT TemplateConfig
+	err := n.Decode((*TemplateConfigT)(c))
+	if err != nil {
+		return err
+	}
+	if c.Delims != nil && len(c.Delims) != 2 {
+		return fmt.Errorf("line %d: delims must be a list of a left and right delimiter", n.Line)
+	}
+	return nil
+}
+
+func (c *TemplateConfig) MarshalYAML() (interface{}, error) {
+	path := c.Path
+	c.Path = ""
+	type TemplateConfigT TemplateConfig
+	return map[string]*TemplateConfigT{path: (*TemplateConfigT)(c)}, nil
+}
+
+func (b TemplateBase) Enabled() bool {
+	return true
+}
+
+func (b TemplateBase) RunAll() error {
+	hasError := false
+	for _, config := range b {
+		err := config.Run()
+		if sudo.IsPermission(err) && sudo.WouldSudo() {
+			if !sudo.HasUsedSudo {
+				templateLogger.TagSudo("rendering", true).Path(
+					emerald.HighlightPath(config.Source, 0),
+					emerald.HighlightPath(config.Path, 0),
+				)
+			}
+			err = sudo.Config("template", &config)
+		}
+		if err != nil {
+			reportError(err)
+			hasError = true
+		}
+	}
+	if hasError {
+		return errors.New("failed to render some templates")
+	}
+	return nil
+}
+
+func (c TemplateConfig) Run() error {
+	err := template.RenderField(&c.Path, &c.Source)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(utils.ExpandUser(c.Source))
+	if err != nil {
+		return err
+	}
+	tmpl := template.New(filepath.Base(c.Source))
+	if c.Delims != nil {
+		tmpl.Delims(c.Delims[0], c.Delims[1])
+	}
+	tmpl, err = tmpl.TryParse(string(data))
+	if err != nil {
+		return err
+	}
+	output, err := tmpl.Render()
+	if err != nil {
+		return err
+	}
+
+	path := utils.ExpandUser(c.Path)
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	// hash of the last rendered output, used to detect local modifications
+	key := "template:" + absPath
+	outputHash := hashString(output)
+	mode := os.FileMode(c.Mode)
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	exists := err == nil
+	if exists && string(existing) == output {
+		changed, err := c.applyAttrs(path, mode)
+		if err != nil {
+			return err
+		}
+		if store.Manifest.Get(key) != outputHash && !store.DryRun {
+			err := store.Manifest.SetSave(key, outputHash)
+			if err != nil {
+				return err
+			}
+		}
+		if changed {
+			templateLogger.TagSudo("updated").Print(
+				emerald.HighlightFileMode(mode), " ", emerald.HighlightPath(c.Path, mode), "\n",
+			)
+		} else {
+			templateLogger.TagDone("unchanged").Path(
+				emerald.HighlightPath(c.Source, 0),
+				emerald.HighlightPath(c.Path, mode),
+			)
+		}
+		return nil
+	}
+	if exists && !utils.IsWritable(path) {
+		return os.ErrPermission
+	}
+
+	if exists && (log.EnableDebug || store.DryRun) {
+		emerald.Print(utils.Diff(string(existing), output))
+	}
+	if exists && store.Manifest.Get(key) != hashString(string(existing)) {
+		// the file was modified since it was last rendered
+		dest, err := backup.Save(path, "template")
+		if err != nil {
+			return err
+		}
+		templateLogger.TagC(emerald.Red, "backed up").Path(
+			emerald.HighlightPath(c.Path, mode),
+			emerald.HighlightPath(utils.ShrinkUser(dest), mode),
+		)
+	}
+
+	if !store.DryRun {
+		if c.Mkdirs {
+			err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+			if err != nil {
+				return err
+			}
+		}
+		err := os.WriteFile(path, []byte(output), mode)
+		if err != nil {
+			return err
+		}
+		_, err = c.applyAttrs(path, mode)
+		if err != nil {
+			return err
+		}
+		err = store.Manifest.SetSave(key, outputHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	tag := "rendered"
+	if exists {
+		tag = "updated"
+	}
+	templateLogger.TagSudo(tag).Path(
+		emerald.HighlightPath(c.Source, 0),
+		emerald.HighlightPath(c.Path, mode),
+	)
+	return nil
+}
+
+// applyAttrs sets the mode and owner of the file if they differ,
+// returns true if anything was changed
+func (c TemplateConfig) applyAttrs(path string, mode os.FileMode) (bool, error) {
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) && store.DryRun {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	changed := false
+	if stat.Mode().Perm() != mode.Perm() {
+		if !store.DryRun {
+			err := os.Chmod(path, mode.Perm())
+			if err != nil {
+				return false, err
+			}
+		}
+		changed = true
+	}
+	if c.Owner != "" {
+		uid, gid, err := utils.LookupOwner(c.Owner)
+		if err != nil {
+			return false, err
+		}
+		if !utils.HasOwner(stat, uid, gid) {
+			if !store.DryRun {
+				err := os.Chown(path, uid, gid)
+				if err != nil {
+					return false, err
+				}
+			}
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
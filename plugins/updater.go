@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/store"
@@ -13,7 +14,15 @@ import (
 	"syscall"
 )
 
+// checkedUpdate is set once dotbot has checked for an update,
+// so that it is only checked once when applying multiple sources
+var checkedUpdate = false
+
 func UpdaterUpdate() {
+	if checkedUpdate {
+		return
+	}
+	checkedUpdate = true
 	latest, err := GetGithubVersion(store.RepoUrl)
 	if err != nil {
 		log.Fatalln("failed to get latest version of dotbot", err)
@@ -23,7 +32,8 @@ func UpdaterUpdate() {
 	}
 
 	// quick check assets have been published
-	head, err := http.Head(store.RepoUrl + "/releases/download/" + latest + "/checksums.txt")
+	checksums := store.RepoUrl + "/releases/download/" + latest + "/checksums.txt"
+	head, err := http.Head(checksums)
 	if err != nil {
 		log.Fatalln("failed checking if assets are available", err)
 	}
@@ -44,11 +54,6 @@ func UpdaterUpdate() {
 		return
 	}
 
-	err = os.Rename(exe, exeOld)
-	if err != nil {
-		log.Fatalln("failed to rename executable to '.dotbot.old'", err)
-	}
-
 	// construct asset name
 	arch := runtime.GOARCH
 	ext := ".tar.gz"
@@ -66,10 +71,31 @@ func UpdaterUpdate() {
 	}
 	asset := "dotbot_" + latest + "_" + runtime.GOOS + "_" + arch + ext
 
+	// the archive is always verified against the published checksums, which must
+	// be signed when a public key is embedded in the build, and the current
+	// executable is only replaced once that succeeds
+	checksumData, err := fetchBytes(checksums)
+	if err != nil {
+		log.Fatalln("failed to fetch checksums", err)
+	}
+	if store.PublicKey != "" {
+		err := verifySignature(bytes.NewReader(checksumData), "checksums.txt", checksums+".minisig", store.PublicKey)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	} else {
+		log.Debugln("skipping signature verification as no public key is embedded")
+	}
+	checksum, err := findChecksum(checksumData, asset, checksums)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	// download archive
 	dl := DownloadConfig{
-		Url:  store.RepoUrl + "/releases/download/" + latest + "/" + asset,
-		Mode: 438,
+		Url:    store.RepoUrl + "/releases/download/" + latest + "/" + asset,
+		Mode:   438,
+		Sha256: checksum,
 		Extract: ExtractItems{
 			{
 				Source: "dotbot" + archiveExt,
@@ -79,10 +105,16 @@ func UpdaterUpdate() {
 	}
 	err = dl.Run()
 	if err != nil {
+		_ = os.Remove(exeNew)
 		log.Fatalln("failed to download or extract archive", err)
 	}
 	store.RemoveTempFiles()
 
+	err = os.Rename(exe, exeOld)
+	if err != nil {
+		log.Fatalln("failed to rename executable to '.dotbot.old'", err)
+	}
+
 	// rename new file
 	err = os.Rename(exeNew, exe)
 	if err != nil {
@@ -115,7 +147,7 @@ func UpdaterUpdate() {
 func UpdaterUpdateRepo() (bool, error) {
 	err := GitConfig{
 		Path:    store.BaseDir(),
-		Name:    "dotfiles",
+		Name:    filepath.Base(store.BaseDir()),
 		Method:  "pull",
 		Shallow: false,
 	}.Run()
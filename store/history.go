@@ -0,0 +1,79 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Event records an install or update of a tool
+type Event struct {
+	Tool     string        `json:"tool"`
+	Time     time.Time     `json:"time"`
+	Previous string        `json:"previous,omitempty"`
+	Version  string        `json:"version"`
+	Success  bool          `json:"success"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// historyLocation is a file of json lines kept separate from
+// the state file as it only ever grows
+func historyLocation() string {
+	return filepath.Join(StateDir(), "history.jsonl")
+}
+
+// AddEvent appends an event to the install history
+func AddEvent(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockState(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	file, err := os.OpenFile(historyLocation(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, statePerm)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// History returns the recorded events for a tool in the order they
+// happened, all events are returned if tool is empty
+func History(tool string) ([]Event, error) {
+	unlock, err := lockState(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	file, err := os.Open(historyLocation())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events := make([]Event, 0, 16)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		// skip lines that fail to parse such as a partially written last line
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if tool == "" || event.Tool == tool {
+			events = append(events, event)
+		}
+	}
+	return events, scanner.Err()
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package store
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+	"syscall"
+)
+
+func lockFile(file *os.File, exclusive bool) error {
+	how := unix.LOCK_SH
+	if exclusive {
+		how = unix.LOCK_EX
+	}
+	for {
+		err := unix.Flock(int(file.Fd()), how)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+func unlockFile(file *os.File) error {
+	return unix.Flock(int(file.Fd()), unix.LOCK_UN)
+}
+
+// preserveOwner gives the file the same owner as the file it replaces,
+// so that running as root using sudo does not take ownership of the state
+func preserveOwner(path string, replaces string) {
+	stat, err := os.Stat(replaces)
+	if err != nil {
+		return
+	}
+	if sys, ok := stat.Sys().(*syscall.Stat_t); ok && int(sys.Uid) != os.Getuid() {
+		_ = os.Chown(path, int(sys.Uid), int(sys.Gid))
+	}
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"os"
+	"sort"
+)
+
+// Source is an additional dotfiles directory, sources are applied in order of
+// priority so that higher priority sources override the vars of lower ones
+type Source struct {
+	Path     string `json:"path"`
+	Priority int    `json:"priority"`
+}
+
+// SourceEnv holds the dotfiles directory being applied, it is
+// inherited by dotbot when it is run using sudo
+const SourceEnv = "DOTBOT_SOURCE"
+
+// Sources returns the dotfiles directory, which has a priority of zero,
+// and any additional sources in the order they are applied
+func Sources() []Source {
+	sources := make([]Source, 0, 1+len(get().Sources))
+	if dir, ok := Settings.HasGet("directory"); ok {
+		sources = append(sources, Source{Path: dir})
+	}
+	sources = append(sources, get().Sources...)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Priority < sources[j].Priority
+	})
+	return sources
+}
+
+// AddSource adds or updates an additional source and saves the state file
+func AddSource(source Source) error {
+	get().addSource(source)
+	return Save()
+}
+
+func (s *state) addSource(source Source) {
+	for i, existing := range s.Sources {
+		if existing.Path == source.Path {
+			s.Sources[i] = source
+			return
+		}
+	}
+	s.Sources = append(s.Sources, source)
+}
+
+// RemoveSource removes an additional source, returning false if it is not present
+func RemoveSource(path string) bool {
+	return get().removeSource(path)
+}
+
+func (s *state) removeSource(path string) bool {
+	for i, source := range s.Sources {
+		if source.Path == path {
+			s.Sources = append(s.Sources[:i], s.Sources[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (s *state) source(path string) (Source, bool) {
+	for _, source := range s.Sources {
+		if source.Path == path {
+			return source, true
+		}
+	}
+	return Source{}, false
+}
+
+// SetSource sets the dotfiles directory being applied
+func SetSource(path string) {
+	_ = os.Setenv(SourceEnv, path)
+}
+
+// BaseDir returns the dotfiles directory being applied,
+// otherwise the configured dotfiles directory
+func BaseDir() string {
+	if dir := os.Getenv(SourceEnv); dir != "" {
+		return dir
+	}
+	return Settings.Get("directory")
+}
@@ -0,0 +1,507 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/jcwillox/dotbot/log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the state file format, files written by
+// older versions are migrated when they are loaded
+const SchemaVersion = 2
+
+const statePerm os.FileMode = 0600
+
+type state struct {
+	Version  int                `json:"version"`
+	Settings map[string]string  `json:"settings"`
+	Installs map[string]Install `json:"installs"`
+	Prompts  map[string]string  `json:"prompts"`
+	Manifest map[string]string  `json:"manifest"`
+	Sources  []Source           `json:"sources,omitempty"`
+}
+
+// Install records the version of a tool installed by the install directive
+type Install struct {
+	Version string     `json:"version"`
+	Url     string     `json:"url,omitempty"`
+	Time    *time.Time `json:"time,omitempty"`
+}
+
+var (
+	current  *state
+	loadErr  error
+	location string
+	// loaded is a copy of the state as it was last read or written, it is
+	// compared to the current state to find the changes made by this process
+	loaded *state
+	// replaced is set when the whole state is replaced rather than changed
+	replaced bool
+)
+
+func newState() *state {
+	return &state{
+		Version:  SchemaVersion,
+		Settings: make(map[string]string),
+		Installs: make(map[string]Install),
+		Prompts:  make(map[string]string),
+		Manifest: make(map[string]string),
+	}
+}
+
+// Load reads the state file the first time it is called, if it cannot be read
+// an empty state is used instead and saving is refused to avoid losing data
+func Load() error {
+	if current != nil {
+		return loadErr
+	}
+	current, loadErr = readState()
+	if loadErr != nil {
+		log.Warnln("Failed to load state file", location+", changes will not be saved:", loadErr)
+		current = newState()
+	}
+	loaded = current.clone()
+	return loadErr
+}
+
+func get() *state {
+	_ = Load()
+	return current
+}
+
+func readState() (*state, error) {
+	unlock, err := lockState(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	s, _, err := readFile()
+	return s, err
+}
+
+// readFile reads the state file, the caller must hold the lock, the original
+// contents are returned when the file was migrated from an older version
+func readFile() (s *state, legacy []byte, err error) {
+	data, err := os.ReadFile(location)
+	if os.IsNotExist(err) {
+		return newState(), nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	s, migrated, err := parseState(data)
+	if migrated {
+		legacy = data
+	}
+	return s, legacy, err
+}
+
+// parseState reads a state file, migrating it if it was written by an older version
+func parseState(data []byte) (s *state, migrated bool, err error) {
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, false, err
+	}
+	version := 1
+	if raw, ok := fields["version"]; ok {
+		// a string means a flat v1 file with a setting named version
+		_ = json.Unmarshal(raw, &version)
+	}
+	switch {
+	case version > SchemaVersion:
+		return nil, false, fmt.Errorf("state file has version %d but only %d is supported, update dotbot", version, SchemaVersion)
+	case version < SchemaVersion:
+		var flat map[string]string
+		err := json.Unmarshal(data, &flat)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to migrate state file: %w", err)
+		}
+		return migrateV1(flat), true, nil
+	}
+	s = newState()
+	err = json.Unmarshal(data, s)
+	if err != nil {
+		return nil, false, err
+	}
+	// ensure namespaces removed from the file are usable
+	if s.Settings == nil {
+		s.Settings = make(map[string]string)
+	}
+	if s.Installs == nil {
+		s.Installs = make(map[string]Install)
+	}
+	if s.Prompts == nil {
+		s.Prompts = make(map[string]string)
+	}
+	if s.Manifest == nil {
+		s.Manifest = make(map[string]string)
+	}
+	return s, false, nil
+}
+
+// migrateV1 sorts the keys of the original flat map into namespaces
+func migrateV1(flat map[string]string) *state {
+	s := newState()
+	for key, value := range flat {
+		switch {
+		case strings.HasPrefix(key, "prompt:"):
+			s.Prompts[strings.TrimPrefix(key, "prompt:")] = value
+		case strings.HasPrefix(key, "copy:"), strings.HasPrefix(key, "link_glob:"), strings.HasPrefix(key, "template:"):
+			s.Manifest[key] = value
+		case strings.Contains(key, "://"):
+			// installs were keyed by the url used to check their version
+			s.Installs[key] = Install{Version: value, Url: key}
+		default:
+			s.Settings[key] = value
+		}
+	}
+	return s
+}
+
+func (s *state) clone() *state {
+	c := newState()
+	copyStrings(c.Settings, s.Settings)
+	copyStrings(c.Prompts, s.Prompts)
+	copyStrings(c.Manifest, s.Manifest)
+	for name, install := range s.Installs {
+		c.Installs[name] = install
+	}
+	c.Sources = append(c.Sources, s.Sources...)
+	return c
+}
+
+// merge applies the changes made between the states from and to
+func (s *state) merge(from, to *state) {
+	mergeStrings(s.Settings, from.Settings, to.Settings)
+	mergeStrings(s.Prompts, from.Prompts, to.Prompts)
+	mergeStrings(s.Manifest, from.Manifest, to.Manifest)
+	for name, install := range to.Installs {
+		if prev, ok := from.Installs[name]; !ok || !reflect.DeepEqual(prev, install) {
+			s.Installs[name] = install
+		}
+	}
+	for name := range from.Installs {
+		if _, ok := to.Installs[name]; !ok {
+			delete(s.Installs, name)
+		}
+	}
+	for _, source := range to.Sources {
+		if prev, ok := from.source(source.Path); !ok || prev != source {
+			s.addSource(source)
+		}
+	}
+	for _, source := range from.Sources {
+		if _, ok := to.source(source.Path); !ok {
+			s.removeSource(source.Path)
+		}
+	}
+}
+
+func copyStrings(dst, src map[string]string) {
+	for key, value := range src {
+		dst[key] = value
+	}
+}
+
+func mergeStrings(dst, from, to map[string]string) {
+	for key, value := range to {
+		if prev, ok := from[key]; !ok || prev != value {
+			dst[key] = value
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			delete(dst, key)
+		}
+	}
+}
+
+// Save writes the state file, replacing it atomically. The file is read again
+// while it is locked and only the changes made by this process are applied to
+// it, so changes made by other processes such as dotbot running under sudo are kept
+func Save() error {
+	s := get()
+	if loadErr != nil {
+		return fmt.Errorf("not saving state file as it failed to load: %w", loadErr)
+	}
+	unlock, err := lockState(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	merged, legacy, err := readFile()
+	if err != nil {
+		return err
+	}
+	if replaced {
+		merged = s
+	} else {
+		merged.merge(loaded, s)
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return err
+	}
+	if legacy != nil {
+		err := os.WriteFile(location+".v1.bak", legacy, statePerm)
+		if err != nil {
+			return err
+		}
+	}
+	err = writeAtomic(location, data)
+	if err != nil {
+		return err
+	}
+	current, loaded, replaced = merged, merged.clone(), false
+	return nil
+}
+
+// writeAtomic writes to a temporary file which then replaces the file
+// so that it is never left partially written
+func writeAtomic(path string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	temp := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(temp, statePerm)
+	}
+	if err == nil {
+		preserveOwner(temp, path)
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		_ = os.Remove(temp)
+	}
+	return err
+}
+
+// lockState locks the state file until unlock is called, exclusive
+// locks are used for writing and shared locks for reading
+func lockState(exclusive bool) (unlock func(), err error) {
+	err = os.MkdirAll(filepath.Dir(location), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(location+".lock", os.O_RDONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = lockFile(file, exclusive)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock state file: %w", err)
+	}
+	return func() {
+		_ = unlockFile(file)
+		file.Close()
+	}, nil
+}
+
+// Namespace is a group of string values in the state file
+type Namespace struct {
+	name string
+	data func(s *state) map[string]string
+}
+
+var (
+	// Settings are properties set by the user such as the dotfiles directory
+	Settings = Namespace{"settings", func(s *state) map[string]string { return s.Settings }}
+	// Prompts are the saved answers to prompts
+	Prompts = Namespace{"prompts", func(s *state) map[string]string { return s.Prompts }}
+	// Manifest records what has been deployed such as the hashes of copies and templates
+	Manifest = Namespace{"manifest", func(s *state) map[string]string { return s.Manifest }}
+)
+
+func (ns Namespace) Name() string {
+	return ns.name
+}
+
+func (ns Namespace) Get(key string) string {
+	return ns.data(get())[key]
+}
+
+func (ns Namespace) HasGet(key string) (string, bool) {
+	value, present := ns.data(get())[key]
+	return value, present
+}
+
+func (ns Namespace) Set(key, value string) {
+	ns.data(get())[key] = value
+}
+
+func (ns Namespace) SetSave(key, value string) error {
+	ns.Set(key, value)
+	return Save()
+}
+
+func (ns Namespace) Unset(key string) {
+	delete(ns.data(get()), key)
+}
+
+// Keys returns all keys in the namespace in sorted order
+func (ns Namespace) Keys() []string {
+	return sortedKeys(ns.data(get()))
+}
+
+// GetInstall returns the recorded install of a tool
+func GetInstall(name string) (Install, bool) {
+	install, present := get().Installs[name]
+	return install, present
+}
+
+// SetInstall records the install of a tool and saves the state file
+func SetInstall(name string, install Install) error {
+	get().Installs[name] = install
+	return Save()
+}
+
+func UnsetInstall(name string) {
+	delete(get().Installs, name)
+}
+
+// ClearInstall keeps the record of a tool but clears its version, marking
+// that it should be installed again even if it is detected on the system
+func ClearInstall(name string) {
+	get().Installs[name] = Install{}
+}
+
+// Installs returns the names of all recorded installs in sorted order
+func Installs() []string {
+	names := make([]string, 0, len(get().Installs))
+	for name := range get().Installs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Namespaces are the names of the namespaces in the state file
+var Namespaces = []string{"settings", "installs", "prompts", "manifest", "sources"}
+
+// Entries returns the values in a namespace by its name, installs are
+// returned as Install and all other values as strings
+func Entries(namespace string) (map[string]interface{}, error) {
+	s := get()
+	entries := make(map[string]interface{})
+	switch namespace {
+	case "installs":
+		for name, install := range s.Installs {
+			entries[name] = install
+		}
+		return entries, nil
+	case "sources":
+		for _, source := range s.Sources {
+			entries[source.Path] = source.Priority
+		}
+		return entries, nil
+	}
+	ns, err := lookupNamespace(namespace)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range ns.data(s) {
+		entries[key] = value
+	}
+	return entries, nil
+}
+
+// UnsetEntry removes a value from a namespace by its name, returning
+// false if the value is not present
+func UnsetEntry(namespace, key string) (bool, error) {
+	switch namespace {
+	case "installs":
+		_, present := GetInstall(key)
+		UnsetInstall(key)
+		return present, nil
+	case "sources":
+		return RemoveSource(key), nil
+	}
+	ns, err := lookupNamespace(namespace)
+	if err != nil {
+		return false, err
+	}
+	_, present := ns.HasGet(key)
+	ns.Unset(key)
+	return present, nil
+}
+
+func lookupNamespace(name string) (Namespace, error) {
+	for _, ns := range []Namespace{Settings, Prompts, Manifest} {
+		if ns.name == name {
+			return ns, nil
+		}
+	}
+	return Namespace{}, fmt.Errorf("unknown namespace '%s', expected one of %s", name, strings.Join(Namespaces, ", "))
+}
+
+// Export returns the contents of the state file without the answers of the omitted prompts
+func Export(omitPrompts []string) ([]byte, error) {
+	s := get()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if len(omitPrompts) > 0 {
+		s = s.clone()
+		for _, name := range omitPrompts {
+			delete(s.Prompts, name)
+		}
+	}
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// Import merges an exported state file into the current state and saves it,
+// when replace is true the current state is discarded instead
+func Import(data []byte, replace bool) error {
+	imported, _, err := parseState(data)
+	if err != nil {
+		return err
+	}
+	s := get()
+	if loadErr != nil && !replace {
+		return fmt.Errorf("cannot merge into a state file that failed to load: %w", loadErr)
+	}
+	if replace {
+		current, loadErr, replaced = imported, nil, true
+		return Save()
+	}
+	for key, value := range imported.Settings {
+		s.Settings[key] = value
+	}
+	for name, install := range imported.Installs {
+		s.Installs[name] = install
+	}
+	for key, value := range imported.Prompts {
+		s.Prompts[key] = value
+	}
+	for key, value := range imported.Manifest {
+		s.Manifest[key] = value
+	}
+	for _, source := range imported.Sources {
+		s.addSource(source)
+	}
+	return Save()
+}
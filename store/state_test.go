@@ -0,0 +1,106 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useStateFile points the store at a new state file and clears the loaded state
+func useStateFile(t *testing.T) string {
+	location = filepath.Join(t.TempDir(), "state.json")
+	current, loadErr, loaded, replaced = nil, nil, nil, false
+	return location
+}
+
+func TestSaveKeepsChangesOfOtherProcesses(t *testing.T) {
+	path := useStateFile(t)
+	Settings.Set("directory", "/dotfiles")
+	Manifest.Set("copy:/a", "1")
+	Manifest.Set("copy:/b", "1")
+	if err := Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// another process, such as dotbot under sudo, changes the file
+	err := os.WriteFile(path, []byte(`{
+  "version": 2,
+  "settings": {"directory": "/dotfiles"},
+  "installs": {"fzf": {"version": "0.40.0"}},
+  "manifest": {"copy:/a": "1", "copy:/b": "2", "template:/c": "3"},
+  "sources": [{"path": "/extra", "priority": 1}]
+}`), statePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changes made by this process from its stale snapshot
+	Manifest.Set("copy:/a", "changed")
+	Manifest.Unset("copy:/b")
+	Prompts.Set("name", "value")
+	if err := Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, loaded = nil, nil
+	want := map[string]string{"copy:/a": "changed", "template:/c": "3"}
+	if got := len(Manifest.Keys()); got != len(want) {
+		t.Errorf("manifest has %d keys %v, want %v", got, Manifest.Keys(), want)
+	}
+	for key, value := range want {
+		if got := Manifest.Get(key); got != value {
+			t.Errorf("manifest %s = %q, want %q", key, got, value)
+		}
+	}
+	if install, ok := GetInstall("fzf"); !ok || install.Version != "0.40.0" {
+		t.Errorf("install of other process was lost: %v", install)
+	}
+	if Prompts.Get("name") != "value" {
+		t.Errorf("prompt was not saved")
+	}
+	if len(Sources()) != 2 {
+		t.Errorf("sources = %v, want the directory and /extra", Sources())
+	}
+}
+
+func TestImportReplace(t *testing.T) {
+	useStateFile(t)
+	Settings.Set("directory", "/dotfiles")
+	Manifest.Set("copy:/a", "1")
+	if err := Save(); err != nil {
+		t.Fatal(err)
+	}
+	err := Import([]byte(`{"version": 2, "settings": {"other": "1"}}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, loaded = nil, nil
+	if Settings.Get("directory") != "" || Manifest.Get("copy:/a") != "" || Settings.Get("other") != "1" {
+		t.Errorf("import did not replace the state file: %v %v", Settings.Keys(), Manifest.Keys())
+	}
+}
+
+func TestMigrateV1(t *testing.T) {
+	path := useStateFile(t)
+	err := os.WriteFile(path, []byte(`{
+  "directory": "/dotfiles",
+  "prompt:name": "value",
+  "copy:/a": "1",
+  "https://github.com/junegunn/fzf": "0.40.0"
+}`), statePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Settings.Get("directory") != "/dotfiles" || Prompts.Get("name") != "value" || Manifest.Get("copy:/a") != "1" {
+		t.Errorf("v1 keys were not migrated into namespaces")
+	}
+	if install, ok := GetInstall("https://github.com/junegunn/fzf"); !ok || install.Version != "0.40.0" {
+		t.Errorf("v1 install was not migrated: %v", install)
+	}
+	if err := Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".v1.bak"); err != nil {
+		t.Errorf("v1 state file was not backed up: %v", err)
+	}
+}
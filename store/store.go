@@ -1,7 +1,6 @@
 package store
 
 import (
-	"encoding/json"
 	"github.com/jcwillox/dotbot/log"
 	"os"
 	"path/filepath"
@@ -10,72 +9,20 @@ import (
 
 var (
 	DryRun           = false
+	Adopt            = false
 	Groups           []string
 	RegisteredGroups []string
 	HomeDirectory    string
 	Version          = "devel"
 	RepoUrl          = "https://github.com/jcwillox/dotbot"
+	// PublicKey is the minisign key releases are signed with, it is set at
+	// build time and self-updates are only verified by checksum without it
+	PublicKey = ""
 )
 
-var (
-	store    map[string]string
-	location string
-)
-
-const storePerm os.FileMode = 0600
-
-func Set(key, value string) {
-	store[key] = value
-}
-
-func Get(key string) string {
-	return store[key]
-}
-
-func HasGet(key string) (string, bool) {
-	val, present := store[key]
-	return val, present
-}
-
-func Save() error {
-	data, err := json.MarshalIndent(store, "", "  ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(location, data, storePerm)
-}
-
-func SetSave(key, value string) error {
-	Set(key, value)
-	return Save()
-}
-
-func Load() {
-	_, err := os.Stat(location)
-	if os.IsNotExist(err) {
-		err := os.MkdirAll(filepath.Dir(location), os.ModePerm)
-		if err != nil {
-			log.Fatalln("Failed to create folder for state file", err)
-		}
-		err = os.WriteFile(location, []byte("{}"), storePerm)
-		if err != nil {
-			log.Fatalln("Failed to write default state file", err)
-		}
-	} else if err != nil {
-		log.Fatalln("Failed to stat state file", err)
-	}
-	data, err := os.ReadFile(location)
-	if err != nil {
-		log.Fatalln("Failed to read state file", err)
-	}
-	err = json.Unmarshal(data, &store)
-	if err != nil {
-		log.Fatalln("Failed to unmarshal state file", err)
-	}
-}
-
-func BaseDir() string {
-	return Get("directory")
+// StateDir returns the directory containing the state file
+func StateDir() string {
+	return filepath.Dir(location)
 }
 
 func getHome() string {
@@ -87,7 +34,7 @@ func getHome() string {
 }
 
 func getLocation() string {
-	if location = os.Getenv("XDG_STATE_HOME"); location != "" {
+	if location := os.Getenv("XDG_STATE_HOME"); location != "" {
 		return location
 	}
 	if runtime.GOOS == "windows" {
@@ -103,10 +50,12 @@ func getLocation() string {
 func init() {
 	HomeDirectory = getHome()
 	location = getLocation()
-	Load()
 	if dryRun := os.Getenv("DRY_RUN"); dryRun == "true" {
 		DryRun = true
 	}
+	if adopt := os.Getenv("DOTBOT_ADOPT"); adopt == "true" {
+		Adopt = true
+	}
 }
 
 var tempFiles = make([]string, 0, 5)
@@ -118,7 +67,7 @@ func TrackTempFile(path string) {
 func RemoveTempFiles() {
 	for _, path := range tempFiles {
 		err := os.Remove(path)
-		if err != nil {
+		if err != nil && !os.IsNotExist(err) {
 			log.Fatalln("Failed removing temporary file", err)
 		}
 	}
@@ -166,3 +115,16 @@ func VarsClosure(vars map[string]interface{}) func() {
 		}
 	}
 }
+
+var tmplDefs = make(map[string]string)
+
+// TmplDefs adds named templates defined in the config
+func TmplDefs(defs map[string]string) {
+	for name, tmpl := range defs {
+		tmplDefs[name] = tmpl
+	}
+}
+
+func GetTmplDefs() map[string]string {
+	return tmplDefs
+}
@@ -0,0 +1,290 @@
+package template
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/facts"
+	"github.com/jcwillox/dotbot/utils"
+	"golang.org/x/sys/execabs"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Function struct {
+	Name string `json:"name"`
+	Desc string `json:"description"`
+	fn   interface{}
+}
+
+// functions available in templates, functions taking a value to operate on
+// take it as the last argument so that they can be used in pipelines
+var functions = []Function{
+	// environment and platform
+	{"env", "Value of an environment variable", os.Getenv},
+	{"fact", "Value of a fact, see dotbot facts", func(name string) (interface{}, error) {
+		value, ok := facts.Get(name)
+		if !ok {
+			return nil, errors.New("unknown fact '" + name + "'")
+		}
+		return value, nil
+	}},
+	{"Distro", "Display name of the distro or OS", facts.Distro},
+	{"MatchDistro", "True if the distro starts with the given name, ignoring case", facts.MatchDistro},
+	{"OS", "Operating system e.g. linux, windows, darwin", func() string { return facts.String("os") }},
+	{"ARCH", "Architecture e.g. amd64, arm64", func() string { return facts.String("arch") }},
+	{"DefaultShell", "Path of the users default shell", func() string { return facts.String("shell") }},
+	{"IsWSL", "True if running under WSL", func() bool { return facts.Bool("wsl") }},
+	{"IsMusl", "True if the system uses musl libc", func() bool { return facts.String("libc") == "musl" }},
+	{"LIBC", "System libc either gnu or musl", func() string { return facts.String("libc") }},
+	{"IsRoot", "True if running as root", func() bool { return facts.Bool("is_root") }},
+	{"CanSudo", "True if the user can use sudo", func() bool { return facts.Bool("can_sudo") }},
+	{"OnPath", "True if the executable is on the PATH", utils.OnPath},
+	{"Local", "Directory tools are installed to e.g. ~/.local", utils.GetLocal},
+	{"prompt", "Saved answer to a prompt, asking for it the first time: prompt name [message] [default]", func(name string, args ...string) (interface{}, error) {
+		p := utils.Prompt{Name: name, Type: utils.PromptText}
+		if len(args) > 0 {
+			p.Message = args[0]
+		}
+		if len(args) > 1 {
+			p.Default = &args[1]
+		}
+		return p.Answer()
+	}},
+	{"Which", "Path to an executable on the PATH, empty if not found", func(file string) string {
+		path, _ := execabs.LookPath(file)
+		return path
+	}},
+
+	// strings
+	{"trim", "Remove leading and trailing whitespace", strings.TrimSpace},
+	{"trimAll", "Remove the given characters from both ends: trimAll cutset s", func(cutset, s string) string {
+		return strings.Trim(s, cutset)
+	}},
+	{"trimPrefix", "Remove a prefix: trimPrefix prefix s", func(prefix, s string) string {
+		return strings.TrimPrefix(s, prefix)
+	}},
+	{"trimSuffix", "Remove a suffix: trimSuffix suffix s", func(suffix, s string) string {
+		return strings.TrimSuffix(s, suffix)
+	}},
+	{"lower", "Convert to lower case", strings.ToLower},
+	{"upper", "Convert to upper case", strings.ToUpper},
+	{"replace", "Replace all occurrences: replace old new s", func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	}},
+	{"contains", "True if s contains substr: contains substr s", func(substr, s string) bool {
+		return strings.Contains(s, substr)
+	}},
+	{"hasPrefix", "True if s starts with prefix: hasPrefix prefix s", func(prefix, s string) bool {
+		return strings.HasPrefix(s, prefix)
+	}},
+	{"hasSuffix", "True if s ends with suffix: hasSuffix suffix s", func(suffix, s string) bool {
+		return strings.HasSuffix(s, suffix)
+	}},
+	{"split", "Split into a list: split sep s", func(sep, s string) []string {
+		return strings.Split(s, sep)
+	}},
+	{"join", "Join a list into a string: join sep list", func(sep string, list interface{}) string {
+		return strings.Join(toStrings(list), sep)
+	}},
+	{"repeat", "Repeat a string: repeat count s", func(count int, s string) string {
+		return strings.Repeat(s, count)
+	}},
+	{"quote", "Wrap in double quotes escaping as needed", func(s interface{}) string {
+		return fmt.Sprintf("%q", fmt.Sprint(s))
+	}},
+	{"indent", "Indent every line: indent spaces s", indent},
+	{"nindent", "Like indent but starts with a newline", func(spaces int, s string) string {
+		return "\n" + indent(spaces, s)
+	}},
+	{"regexMatch", "True if s matches the regex: regexMatch regex s", func(regex, s string) (bool, error) {
+		return regexp.MatchString(regex, s)
+	}},
+	{"regexFind", "First match of the regex: regexFind regex s", func(regex, s string) (string, error) {
+		r, err := regexp.Compile(regex)
+		if err != nil {
+			return "", err
+		}
+		return r.FindString(s), nil
+	}},
+	{"regexReplaceAll", "Replace matches, $1 expands to a submatch: regexReplaceAll regex repl s", func(regex, repl, s string) (string, error) {
+		r, err := regexp.Compile(regex)
+		if err != nil {
+			return "", err
+		}
+		return r.ReplaceAllString(s, repl), nil
+	}},
+
+	// defaults
+	{"default", "Value if it is not empty, otherwise the default: default def value", func(def interface{}, value ...interface{}) interface{} {
+		if len(value) == 0 || isEmpty(value[0]) {
+			return def
+		}
+		return value[0]
+	}},
+	{"coalesce", "First value that is not empty", func(values ...interface{}) interface{} {
+		for _, value := range values {
+			if !isEmpty(value) {
+				return value
+			}
+		}
+		return nil
+	}},
+	{"empty", "True if the value is missing, zero or has no items", isEmpty},
+	{"ternary", "First value if the condition is true otherwise the second: ternary a b cond", func(a, b interface{}, cond bool) interface{} {
+		if cond {
+			return a
+		}
+		return b
+	}},
+
+	// paths and files
+	{"pathJoin", "Join path elements with the OS separator", filepath.Join},
+	{"base", "Last element of a path", filepath.Base},
+	{"dir", "All but the last element of a path", filepath.Dir},
+	{"ext", "File extension of a path including the dot", filepath.Ext},
+	{"abs", "Absolute path, expanding ~", func(path string) (string, error) {
+		return filepath.Abs(utils.ExpandUser(path))
+	}},
+	{"exists", "True if the path exists", func(path string) bool {
+		_, err := os.Stat(utils.ExpandUser(path))
+		return err == nil
+	}},
+	{"isDir", "True if the path is a directory", func(path string) bool {
+		stat, err := os.Stat(utils.ExpandUser(path))
+		return err == nil && stat.IsDir()
+	}},
+	{"isFile", "True if the path is a regular file", func(path string) bool {
+		stat, err := os.Stat(utils.ExpandUser(path))
+		return err == nil && stat.Mode().IsRegular()
+	}},
+	{"readFile", "Contents of a file", func(path string) (string, error) {
+		data, err := os.ReadFile(utils.ExpandUser(path))
+		return string(data), err
+	}},
+
+	// serialization
+	{"toJson", "Encode as JSON", func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	}},
+	{"toPrettyJson", "Encode as indented JSON", func(v interface{}) (string, error) {
+		data, err := json.MarshalIndent(v, "", "  ")
+		return string(data), err
+	}},
+	{"fromJson", "Decode JSON into a value", func(s string) (interface{}, error) {
+		var v interface{}
+		err := json.Unmarshal([]byte(s), &v)
+		return v, err
+	}},
+	{"toYaml", "Encode as YAML", func(v interface{}) (string, error) {
+		data, err := yaml.Marshal(v)
+		return strings.TrimSuffix(string(data), "\n"), err
+	}},
+	{"fromYaml", "Decode YAML into a value", func(s string) (interface{}, error) {
+		var v interface{}
+		err := yaml.Unmarshal([]byte(s), &v)
+		return v, err
+	}},
+
+	// versions
+	{"semver", "Parse a version, has the fields .Parts and .Pre", utils.ParseVersion},
+	{"semverCompare", "True if the version satisfies the constraint: semverCompare \">=1.2, <2\" version", utils.CheckConstraint},
+	{"versionCompare", "Compare two versions returning -1, 0 or 1", utils.CompareVersions},
+
+	// hashing
+	{"sha256sum", "SHA256 hex digest of a string", func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}},
+	{"sha1sum", "SHA1 hex digest of a string", func(s string) string {
+		sum := sha1.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}},
+	{"md5sum", "MD5 hex digest of a string", func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}},
+
+	// time
+	{"now", "Current time", time.Now},
+	{"date", "Format a time or unix timestamp using a Go layout: date \"2006-01-02\" t", func(layout string, t interface{}) (string, error) {
+		tm, err := toTime(t)
+		return tm.Format(layout), err
+	}},
+	{"unixEpoch", "Seconds since the unix epoch of a time", func(t time.Time) int64 {
+		return t.Unix()
+	}},
+}
+
+var funcs = funcMap()
+
+func funcMap() map[string]interface{} {
+	m := make(map[string]interface{}, len(functions))
+	for _, f := range functions {
+		m[f.Name] = f.fn
+	}
+	return m
+}
+
+// Functions returns the functions available in templates
+func Functions() []Function {
+	return functions
+}
+
+func indent(spaces int, s string) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+}
+
+func isEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return value.IsZero()
+}
+
+func toStrings(list interface{}) []string {
+	if list, ok := list.([]string); ok {
+		return list
+	}
+	value := reflect.ValueOf(list)
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return []string{fmt.Sprint(list)}
+	}
+	strs := make([]string, value.Len())
+	for i := range strs {
+		strs[i] = fmt.Sprint(value.Index(i).Interface())
+	}
+	return strs
+}
+
+func toTime(t interface{}) (time.Time, error) {
+	switch t := t.(type) {
+	case time.Time:
+		return t, nil
+	case int:
+		return time.Unix(int64(t), 0), nil
+	case int64:
+		return time.Unix(t, 0), nil
+	case float64:
+		return time.Unix(int64(t), 0), nil
+	}
+	return time.Time{}, fmt.Errorf("unable to convert %T to a time", t)
+}
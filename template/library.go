@@ -0,0 +1,96 @@
+package template
+
+import (
+	"github.com/jcwillox/dotbot/log"
+	"github.com/jcwillox/dotbot/store"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// LibraryDir is the directory in the dotfiles repo containing named templates
+const LibraryDir = "templates"
+
+// LibraryExt is the extension of files in the library directory which are named templates
+const LibraryExt = ".tmpl"
+
+var library *template.Template
+
+// cache of templates parsed by Parse, keyed by their text
+var cache = make(map[string]*Template)
+
+// Define adds named templates which can be used by any template
+func Define(defs map[string]string) {
+	if len(defs) == 0 {
+		return
+	}
+	store.TmplDefs(defs)
+	library = nil
+	cache = make(map[string]*Template)
+}
+
+// loadLibrary parses the named templates from the library directory
+// and those defined in the config, the result is cached until Define is called.
+// Templates which fail to load are skipped with a warning so that they do not
+// break every other template.
+func loadLibrary() *template.Template {
+	if library != nil {
+		return library
+	}
+	lib := template.New("_library").Funcs(funcs)
+
+	// sources are loaded in the order they are applied so
+	// that higher priority sources override templates
+	for _, source := range store.Sources() {
+		dir := filepath.Join(source.Path, LibraryDir)
+		if err := loadLibraryDir(lib, dir); err != nil {
+			log.Warnln("failed to load templates from", dir+":", err)
+		}
+	}
+
+	defs := store.GetTmplDefs()
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := lib.New(name).Parse(defs[name]); err != nil {
+			log.Warnln("skipping template '"+name+"' defined in the config:", err)
+		}
+	}
+
+	library = lib
+	return library
+}
+
+// loadLibraryDir parses the files ending in LibraryExt, other files such as
+// sources of the template directive are ignored, files which fail to parse are
+// skipped with a warning
+func loadLibraryDir(lib *template.Template, dir string) error {
+	return filepath.WalkDir(dir, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if entry.IsDir() || filepath.Ext(p) != LibraryExt {
+			return nil
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		// templates/git/user.tmpl is named "git/user"
+		name, _ := filepath.Rel(dir, p)
+		name = strings.TrimSuffix(filepath.ToSlash(name), LibraryExt)
+		if _, err := lib.New(name).Parse(string(data)); err != nil {
+			log.Warnln("skipping template", p+":", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,73 @@
+package template
+
+import (
+	"github.com/jcwillox/dotbot/store"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useLibrary sets the dotfiles directory, without saving it, and clears the library
+func useLibrary(t *testing.T, dir string) {
+	directory, ok := store.Settings.HasGet("directory")
+	store.Settings.Set("directory", dir)
+	library, cache = nil, make(map[string]*Template)
+	t.Cleanup(func() {
+		if ok {
+			store.Settings.Set("directory", directory)
+		} else {
+			store.Settings.Unset("directory")
+		}
+		library, cache = nil, make(map[string]*Template)
+	})
+}
+
+func TestLibrary(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"git/user.tmpl": `{{ define "email" }}me@example.com{{ end }}name`,
+		"broken.tmpl":   `{{ if }}`,
+		"source.txt":    `{{ .Unknown }}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, LibraryDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useLibrary(t, dir)
+	Define(map[string]string{
+		"greeting": `hello {{ template "git/user" }}`,
+		"bad":      `{{ .Name `,
+	})
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{`{{ template "git/user" }}`, "name"},
+		{`{{ template "email" }}`, "me@example.com"},
+		{`{{ template "greeting" }}`, "hello name"},
+		{`{{ "unrelated" }}`, "unrelated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			// broken templates must not cause unrelated templates to panic
+			result, err := Parse(tt.tmpl).Render()
+			if err != nil {
+				t.Fatalf("Render() error: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("Render() = %q, want %q", result, tt.want)
+			}
+		})
+	}
+	for _, name := range []string{"broken", "bad", "source"} {
+		if _, err := Parse(`{{ template "` + name + `" }}`).Render(); err == nil {
+			t.Errorf("template %q was loaded", name)
+		}
+	}
+}
@@ -2,55 +2,75 @@ package template
 
 import (
 	"bytes"
+	"github.com/jcwillox/dotbot/facts"
 	"github.com/jcwillox/dotbot/store"
-	"github.com/jcwillox/dotbot/utils"
-	"github.com/jcwillox/dotbot/utils/sudo"
-	"golang.org/x/sys/execabs"
-	"os"
-	"runtime"
 	"strings"
 	"text/template"
 )
 
-var funcs = map[string]interface{}{
-	"env":          os.Getenv,
-	"Distro":       Distro,
-	"MatchDistro":  MatchDistro,
-	"OS":           func() string { return runtime.GOOS },
-	"ARCH":         func() string { return runtime.GOARCH },
-	"DefaultShell": utils.DefaultShell,
-	"IsWSL":        utils.IsWSL,
-	"IsMusl":       utils.IsMusl,
-	"LIBC":         utils.GetLibc,
-	"IsRoot":       sudo.IsRoot,
-	"CanSudo":      sudo.CanSudo,
-	"OnPath":       utils.OnPath,
-	"Local":        utils.GetLocal,
-	"Which": func(file string) string {
-		path, _ := execabs.LookPath(file)
-		return path
-	},
-}
-
 type Template struct {
 	Template *template.Template
+	name     string
+	funcs    template.FuncMap
+	left     string
+	right    string
 }
 
 func (t *Template) Parse(tmpl string) *Template {
-	t.Template = template.Must(t.Template.Funcs(funcs).Parse(tmpl))
+	_, err := t.TryParse(tmpl)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// TryParse is like Parse but returns an error instead of panicking
+func (t *Template) TryParse(tmpl string) (*Template, error) {
+	// parse into a copy of the library so named templates can be used
+	clone, err := loadLibrary().Clone()
+	if err != nil {
+		return nil, err
+	}
+	clone = clone.New(t.name).Delims(t.left, t.right)
+	if t.funcs != nil {
+		clone.Funcs(t.funcs)
+	}
+	parsed, err := clone.Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	t.Template = parsed
+	return t, nil
+}
+
+// Delims sets the action delimiters, an empty delimiter uses the default
+func (t *Template) Delims(left, right string) *Template {
+	t.left, t.right = left, right
 	return t
 }
 
 func New(name string) *Template {
-	return &Template{template.New(name)}
+	return &Template{name: name}
 }
 
+// Parse parses the template, templates are cached so parsing
+// the same text again is cheap
 func Parse(tmpl string) *Template {
-	return New("").Parse(tmpl)
+	if t, ok := cache[tmpl]; ok {
+		return t
+	}
+	t := New("").Parse(tmpl)
+	cache[tmpl] = t
+	return t
 }
 
 func (t *Template) Funcs(funcMap template.FuncMap) *Template {
-	t.Template = t.Template.Funcs(funcMap)
+	if t.funcs == nil {
+		t.funcs = make(template.FuncMap, len(funcMap))
+	}
+	for name, fn := range funcMap {
+		t.funcs[name] = fn
+	}
 	return t
 }
 
@@ -60,10 +80,22 @@ func Funcs(funcMap template.FuncMap) *Template {
 
 func (t *Template) Render() (string, error) {
 	var buff bytes.Buffer
-	err := t.Template.Execute(&buff, store.GetVars())
+	err := t.Template.Execute(&buff, data())
 	return buff.String(), err
 }
 
+// data returns the template vars along with the facts as .Facts and .Distro
+func data() map[string]interface{} {
+	vars := store.GetVars()
+	data := make(map[string]interface{}, len(vars)+2)
+	data["Facts"] = facts.Values{}
+	data["Distro"] = facts.DistroInfo{}
+	for key, val := range vars {
+		data[key] = val
+	}
+	return data
+}
+
 func (t *Template) RenderTrue() (bool, error) {
 	result, err := t.Render()
 	if err != nil {
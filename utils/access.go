@@ -5,8 +5,19 @@ package utils
 
 import (
 	"golang.org/x/sys/unix"
+	"os"
+	"syscall"
 )
 
 func IsWritable(path string) bool {
 	return unix.Access(path, unix.W_OK) == nil
 }
+
+// HasOwner returns true if the file is owned by uid and gid, a gid of -1 is ignored
+func HasOwner(stat os.FileInfo, uid, gid int) bool {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return true
+	}
+	return int(sys.Uid) == uid && (gid == -1 || int(sys.Gid) == gid)
+}
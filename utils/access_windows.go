@@ -1,5 +1,11 @@
 package utils
 
+import "os"
+
 func IsWritable(path string) bool {
 	return true
 }
+
+func HasOwner(stat os.FileInfo, uid, gid int) bool {
+	return true
+}
@@ -0,0 +1,116 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CopyPath copies a file or directory tree from src to dst preserving
+// file modes and symlinks, dst must not already exist
+func CopyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		default:
+			return CopyFile(path, target, info.Mode().Perm())
+		}
+	})
+}
+
+// CopyFile copies the contents of src to a new file at dst with the given mode
+func CopyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// HashPath returns a sha256 hash of the contents of a file or directory tree,
+// file modes are ignored so only changes to contents or names are detected
+func HashPath(path string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(path, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		_, _ = io.WriteString(h, filepath.ToSlash(rel)+"\x00")
+		switch {
+		case entry.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(h, "l"+link+"\x00")
+		case entry.IsDir():
+			_, _ = io.WriteString(h, "d\x00")
+		default:
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, _ = io.WriteString(h, "f")
+			_, err = io.Copy(h, f)
+			if err != nil {
+				return err
+			}
+			_, _ = io.WriteString(h, "\x00")
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// MovePath renames src to dst falling back to copying when they are on different devices
+func MovePath(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if copyErr := CopyPath(src, dst); copyErr != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bytes"
+	"github.com/jcwillox/emerald"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"os"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+// Diff returns a colored line diff between two strings
+func Diff(a, b string) string {
+	dmp := diffmatchpatch.New()
+	charsA, charsB, lines := dmp.DiffLinesToChars(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(charsA, charsB, false), lines)
+
+	var sb strings.Builder
+	for i, diff := range diffs {
+		text := strings.Split(strings.TrimSuffix(diff.Text, "\n"), "\n")
+		switch diff.Type {
+		case diffmatchpatch.DiffDelete:
+			writeLines(&sb, emerald.Red+"-", text)
+		case diffmatchpatch.DiffInsert:
+			writeLines(&sb, emerald.Green+"+", text)
+		case diffmatchpatch.DiffEqual:
+			// only show context lines next to changes
+			if i > 0 && i < len(diffs)-1 && len(text) > diffContext*2 {
+				writeLines(&sb, emerald.LightBlack+" ", text[:diffContext])
+				sb.WriteString(emerald.LightBlack + "@@" + emerald.Reset + "\n")
+				writeLines(&sb, emerald.LightBlack+" ", text[len(text)-diffContext:])
+			} else if i == 0 && len(text) > diffContext {
+				writeLines(&sb, emerald.LightBlack+" ", text[len(text)-diffContext:])
+			} else if i == len(diffs)-1 && len(text) > diffContext {
+				writeLines(&sb, emerald.LightBlack+" ", text[:diffContext])
+			} else {
+				writeLines(&sb, emerald.LightBlack+" ", text)
+			}
+		}
+	}
+	return sb.String()
+}
+
+func writeLines(sb *strings.Builder, prefix string, lines []string) {
+	for _, line := range lines {
+		sb.WriteString(prefix + line + emerald.Reset + "\n")
+	}
+}
+
+// DiffFiles returns a colored line diff between two files, if either is
+// not a text file a short description of the difference is returned instead
+func DiffFiles(a, b string) string {
+	statA, errA := os.Stat(a)
+	statB, errB := os.Stat(b)
+	if errA != nil || errB != nil || !statA.Mode().IsRegular() || !statB.Mode().IsRegular() {
+		return "'" + a + "' and '" + b + "' differ\n"
+	}
+	dataA, errA := os.ReadFile(a)
+	dataB, errB := os.ReadFile(b)
+	if errA != nil || errB != nil || isBinary(dataA) || isBinary(dataB) {
+		return "binary files '" + a + "' and '" + b + "' differ\n"
+	}
+	return Diff(string(dataA), string(dataB))
+}
+
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
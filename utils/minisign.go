@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"io"
+	"strings"
+)
+
+// MinisignKey is a minisign public key
+type MinisignKey struct {
+	ID  [8]byte
+	Key ed25519.PublicKey
+}
+
+// MinisignSignature is a detached minisign signature
+type MinisignSignature struct {
+	// Algorithm is "Ed" for signatures of the whole file or
+	// "ED" for signatures of the blake2b-512 hash of the file
+	Algorithm      string
+	KeyID          [8]byte
+	Signature      []byte
+	TrustedComment string
+	GlobalSig      []byte
+}
+
+// ParseMinisignKey parses a public key either as the base64 encoded
+// key or the contents of a minisign.pub file
+func ParseMinisignKey(s string) (MinisignKey, error) {
+	var key MinisignKey
+	lines := nonEmptyLines(s)
+	if len(lines) == 0 {
+		return key, errors.New("empty minisign public key")
+	}
+	data, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(data) != 42 || string(data[:2]) != "Ed" {
+		return key, errors.New("invalid minisign public key")
+	}
+	copy(key.ID[:], data[2:10])
+	key.Key = data[10:]
+	return key, nil
+}
+
+// ParseMinisignSignature parses the contents of a .minisig file
+func ParseMinisignSignature(data []byte) (MinisignSignature, error) {
+	var sig MinisignSignature
+	invalid := errors.New("invalid minisign signature")
+	lines := nonEmptyLines(string(data))
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return sig, invalid
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(raw) != 74 {
+		return sig, invalid
+	}
+	sig.Algorithm = string(raw[:2])
+	if sig.Algorithm != "Ed" && sig.Algorithm != "ED" {
+		return sig, fmt.Errorf("unsupported minisign algorithm '%s'", sig.Algorithm)
+	}
+	copy(sig.KeyID[:], raw[2:10])
+	sig.Signature = raw[10:]
+	sig.TrustedComment = strings.TrimPrefix(lines[2], "trusted comment: ")
+	sig.GlobalSig, err = base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(sig.GlobalSig) != ed25519.SignatureSize {
+		return sig, invalid
+	}
+	return sig, nil
+}
+
+// Verify checks the signature of the message read from r was made by the key
+func (k MinisignKey) Verify(r io.Reader, sig MinisignSignature) error {
+	if k.ID != sig.KeyID {
+		return fmt.Errorf("signature was made by key %X but expected key %X", reverse(sig.KeyID), reverse(k.ID))
+	}
+	var message []byte
+	if sig.Algorithm == "ED" {
+		hash, _ := blake2b.New512(nil)
+		if _, err := io.Copy(hash, r); err != nil {
+			return err
+		}
+		message = hash.Sum(nil)
+	} else {
+		var err error
+		message, err = io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+	}
+	if !ed25519.Verify(k.Key, message, sig.Signature) {
+		return errors.New("invalid signature")
+	}
+	// the global signature covers the trusted comment
+	if !ed25519.Verify(k.Key, append(append([]byte{}, sig.Signature...), sig.TrustedComment...), sig.GlobalSig) {
+		return errors.New("invalid signature of trusted comment")
+	}
+	return nil
+}
+
+// reverse returns the key id in the byte order minisign displays it
+func reverse(id [8]byte) [8]byte {
+	for i, j := 0, len(id)-1; i < j; i, j = i+1, j-1 {
+		id[i], id[j] = id[j], id[i]
+	}
+	return id
+}
+
+func nonEmptyLines(s string) []string {
+	lines := make([]string, 0, 4)
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
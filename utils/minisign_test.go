@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/blake2b"
+	"strings"
+	"testing"
+)
+
+type testKey struct {
+	id   []byte
+	priv ed25519.PrivateKey
+	pub  string
+}
+
+func newTestKey(t *testing.T) testKey {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	key := append(append([]byte("Ed"), id...), pub...)
+	return testKey{
+		id:   id,
+		priv: priv,
+		pub:  "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(key) + "\n",
+	}
+}
+
+func (k testKey) sign(message []byte, algorithm string, comment string) []byte {
+	if algorithm == "ED" {
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	}
+	sig := ed25519.Sign(k.priv, message)
+	global := ed25519.Sign(k.priv, append(append([]byte{}, sig...), comment...))
+	raw := append(append([]byte(algorithm), k.id...), sig...)
+	return []byte(fmt.Sprintf(
+		"untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(raw), comment, base64.StdEncoding.EncodeToString(global),
+	))
+}
+
+func TestParseMinisignKey(t *testing.T) {
+	key := newTestKey(t)
+	line := strings.Split(key.pub, "\n")[1]
+	for _, s := range []string{key.pub, line, "  " + line + "  "} {
+		parsed, err := ParseMinisignKey(s)
+		if err != nil {
+			t.Fatalf("ParseMinisignKey(%q) error: %v", s, err)
+		}
+		if !bytes.Equal(parsed.ID[:], key.id) {
+			t.Errorf("ParseMinisignKey(%q) id = %X, want %X", s, parsed.ID, key.id)
+		}
+	}
+	for _, s := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("Ed short"))} {
+		if _, err := ParseMinisignKey(s); err == nil {
+			t.Errorf("ParseMinisignKey(%q) expected error", s)
+		}
+	}
+}
+
+func TestMinisignVerify(t *testing.T) {
+	key := newTestKey(t)
+	other := newTestKey(t)
+	message := []byte("abc123  tool_linux_amd64.tar.gz\n")
+
+	tests := []struct {
+		name    string
+		sig     []byte
+		message []byte
+		key     testKey
+		wantErr string
+	}{
+		{"legacy", key.sign(message, "Ed", "file:checksums.txt"), message, key, ""},
+		{"prehashed", key.sign(message, "ED", "file:checksums.txt"), message, key, ""},
+		{"tampered message", key.sign(message, "ED", "file:checksums.txt"), []byte("tampered"), key, "invalid signature"},
+		{"wrong key", other.sign(message, "ED", "file:checksums.txt"), message, key, "signature was made by key"},
+		{
+			"tampered comment",
+			bytes.Replace(key.sign(message, "ED", "file:checksums.txt"), []byte("file:checksums.txt"), []byte("file:other.txt"), 1),
+			message, key, "invalid signature of trusted comment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pub, err := ParseMinisignKey(tt.key.pub)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, err := ParseMinisignSignature(tt.sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = pub.Verify(bytes.NewReader(tt.message), sig)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Verify() error: %v", err)
+			} else if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseMinisignSignature(t *testing.T) {
+	key := newTestKey(t)
+	valid := key.sign([]byte("message"), "ED", "comment")
+	sig, err := ParseMinisignSignature(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Algorithm != "ED" || sig.TrustedComment != "comment" {
+		t.Errorf("ParseMinisignSignature() = %q %q", sig.Algorithm, sig.TrustedComment)
+	}
+	lines := strings.Split(string(valid), "\n")
+	invalid := [][]byte{
+		nil,
+		[]byte(strings.Join(lines[:2], "\n")),
+		[]byte(strings.Join([]string{lines[0], lines[1], "comment", lines[3]}, "\n")),
+		[]byte(strings.Join([]string{lines[0], "AAAA", lines[2], lines[3]}, "\n")),
+	}
+	for _, data := range invalid {
+		if _, err := ParseMinisignSignature(data); err == nil {
+			t.Errorf("ParseMinisignSignature(%q) expected error", data)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// LookupOwner parses an owner in the form user or user:group and returns the
+// uid and gid, the gid is -1 when no group is specified
+func LookupOwner(owner string) (int, int, error) {
+	name, group, hasGroup := strings.Cut(owner, ":")
+	uid, err := strconv.Atoi(name)
+	if err != nil {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return -1, -1, err
+		}
+		uid, err = strconv.Atoi(u.Uid)
+		if err != nil {
+			return -1, -1, err
+		}
+	}
+	if !hasGroup || group == "" {
+		return uid, -1, nil
+	}
+	gid, err := strconv.Atoi(group)
+	if err != nil {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return -1, -1, err
+		}
+		gid, err = strconv.Atoi(g.Gid)
+		if err != nil {
+			return -1, -1, err
+		}
+	}
+	return uid, gid, nil
+}
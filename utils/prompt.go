@@ -0,0 +1,185 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/jcwillox/dotbot/store"
+	"github.com/jcwillox/emerald"
+	"golang.org/x/term"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var ErrNonInteractive = errors.New("unable to prompt for input as stdin is not a terminal")
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// Select asks the user to choose one of the options and returns its index
+func Select(message string, options []string) (int, error) {
+	return selectDefault(message, options, -1)
+}
+
+// selectDefault is like Select but an empty answer chooses def, unless it is negative
+func selectDefault(message string, options []string, def int) (int, error) {
+	if !IsInteractive() {
+		return -1, ErrNonInteractive
+	}
+	emerald.Print(emerald.Bold, message, emerald.Reset, "\n")
+	for i, option := range options {
+		emerald.Print("  ", emerald.Cyan, i+1, ")", emerald.Reset, " ", option)
+		if i == def {
+			emerald.Print(emerald.LightBlack, " (default)", emerald.Reset)
+		}
+		emerald.Print("\n")
+	}
+	for {
+		emerald.Print(emerald.LightBlack, "choose [1-", len(options), "]: ", emerald.Reset)
+		line, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return -1, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && def >= 0 {
+			return def, nil
+		}
+		n, err := strconv.Atoi(line)
+		if err == nil && n > 0 && n <= len(options) {
+			return n - 1, nil
+		}
+	}
+}
+
+// Input asks the user for a line of text, an empty answer returns def
+func Input(message string, def string) (string, error) {
+	if !IsInteractive() {
+		return "", ErrNonInteractive
+	}
+	emerald.Print(emerald.Bold, message, emerald.Reset)
+	if def != "" {
+		emerald.Print(emerald.LightBlack, " [", def, "]", emerald.Reset)
+	}
+	emerald.Print(": ")
+	line, err := stdinReader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return def, nil
+	}
+	return line, nil
+}
+
+// Confirm asks the user a yes or no question, an empty answer returns def
+func Confirm(message string, def bool) (bool, error) {
+	if !IsInteractive() {
+		return false, ErrNonInteractive
+	}
+	hint := " [y/N]: "
+	if def {
+		hint = " [Y/n]: "
+	}
+	for {
+		emerald.Print(emerald.Bold, message, emerald.Reset, emerald.LightBlack, hint, emerald.Reset)
+		line, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// Password asks the user for a secret without echoing it
+func Password(message string) (string, error) {
+	if !IsInteractive() {
+		return "", ErrNonInteractive
+	}
+	emerald.Print(emerald.Bold, message, emerald.Reset, ": ")
+	data, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	return string(data), err
+}
+
+const (
+	PromptText     = "text"
+	PromptChoice   = "choice"
+	PromptConfirm  = "confirm"
+	PromptPassword = "password"
+)
+
+var PromptTypes = []string{PromptText, PromptChoice, PromptConfirm, PromptPassword}
+
+// Prompt is a question whose answer is saved in the state file so that
+// it is only asked the first time it is used on a machine
+type Prompt struct {
+	Name    string
+	Message string
+	Type    string
+	Choices []string
+	Default *string
+}
+
+// Answer returns the saved answer or asks for one, confirm prompts return a
+// bool and all others a string, when not interactive the default is used
+func (p Prompt) Answer() (interface{}, error) {
+	answer, ok := store.Prompts.HasGet(p.Name)
+	if !ok {
+		var err error
+		answer, err = p.ask()
+		if err == ErrNonInteractive {
+			if p.Default == nil {
+				return nil, fmt.Errorf("no saved answer for '%s' and no default, %w", p.Name, err)
+			}
+			answer = *p.Default
+		} else if err != nil {
+			return nil, err
+		} else if store.DryRun {
+			// remember the answer until dotbot exits
+			store.Prompts.Set(p.Name, answer)
+		} else if err := store.Prompts.SetSave(p.Name, answer); err != nil {
+			return nil, err
+		}
+	}
+	if p.Type == PromptConfirm {
+		return strconv.ParseBool(answer)
+	}
+	return answer, nil
+}
+
+func (p Prompt) ask() (string, error) {
+	message := p.Message
+	if message == "" {
+		message = p.Name
+	}
+	def := ""
+	if p.Default != nil {
+		def = *p.Default
+	}
+	switch p.Type {
+	case PromptChoice:
+		i, err := selectDefault(message, p.Choices, IndexOf(p.Choices, def))
+		if err != nil {
+			return "", err
+		}
+		return p.Choices[i], nil
+	case PromptConfirm:
+		b, _ := strconv.ParseBool(def)
+		result, err := Confirm(message, b)
+		return strconv.FormatBool(result), err
+	case PromptPassword:
+		return Password(message)
+	}
+	return Input(message, def)
+}
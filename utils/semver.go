@@ -0,0 +1,277 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Version is a loosely parsed semantic version, any number of numeric
+// parts are allowed e.g. v1.2, 1.2.3-rc.1, 22.04
+type Version struct {
+	Parts []int
+	Pre   string
+}
+
+func ParseVersion(s string) (Version, error) {
+	v, n, err := parseVersion(s, false)
+	if err == nil && n == 0 {
+		err = errors.New("invalid version '" + s + "'")
+	}
+	return v, err
+}
+
+// parseVersion returns the version and the number of parts before a wildcard
+func parseVersion(s string, wildcards bool) (Version, int, error) {
+	invalid := errors.New("invalid version '" + s + "'")
+	str := strings.TrimSpace(s)
+	str = strings.TrimPrefix(strings.TrimPrefix(str, "v"), "V")
+	if i := strings.IndexByte(str, '+'); i >= 0 {
+		// ignore build metadata
+		str = str[:i]
+	}
+	v := Version{}
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		v.Pre = str[i+1:]
+		str = str[:i]
+	}
+	for _, part := range strings.Split(str, ".") {
+		if wildcards && (part == "x" || part == "X" || part == "*") {
+			return v, len(v.Parts), nil
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, 0, invalid
+		}
+		v.Parts = append(v.Parts, n)
+	}
+	return v, len(v.Parts), nil
+}
+
+func (v Version) part(i int) int {
+	if i < len(v.Parts) {
+		return v.Parts[i]
+	}
+	return 0
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than o,
+// missing parts are treated as zero and pre-releases sort before releases
+func (v Version) Compare(o Version) int {
+	n := len(v.Parts)
+	if len(o.Parts) > n {
+		n = len(o.Parts)
+	}
+	for i := 0; i < n; i++ {
+		if a, b := v.part(i), o.part(i); a != b {
+			if a < b {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre compares dot separated pre-release identifiers, numeric
+// identifiers are compared numerically and sort before alphanumeric ones
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	parts := make([]string, len(v.Parts))
+	for i, part := range v.Parts {
+		parts[i] = strconv.Itoa(part)
+	}
+	if v.Pre != "" {
+		return strings.Join(parts, ".") + "-" + v.Pre
+	}
+	return strings.Join(parts, ".")
+}
+
+func (v Version) IsPrerelease() bool {
+	return v.Pre != ""
+}
+
+// CompareVersions parses and compares two versions
+func CompareVersions(a, b string) (int, error) {
+	va, err := ParseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := ParseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// Constraint is a set of version ranges, a version must satisfy all the
+// checks in any one of the ranges
+type Constraint [][]versionCheck
+
+type versionCheck struct {
+	op      string
+	version Version
+	// number of parts specified before a wildcard
+	n int
+}
+
+var constraintOps = []string{">=", "<=", "!=", "==", ">", "<", "=", "~", "^"}
+
+// ParseConstraint parses a version constraint, checks are separated by
+// spaces or commas and ranges by "||" e.g. ">=1.2, <2 || ^3.1", a missing
+// operator means equals and partial versions or wildcards match any
+// value of the remaining parts e.g. "22", "1.2.x"
+func ParseConstraint(s string) (Constraint, error) {
+	constraint := make(Constraint, 0, 1)
+	for _, group := range strings.Split(s, "||") {
+		fields := strings.Fields(strings.ReplaceAll(group, ",", " "))
+		checks := make([]versionCheck, 0, len(fields))
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			op := ""
+			for _, o := range constraintOps {
+				if strings.HasPrefix(field, o) {
+					op = o
+					break
+				}
+			}
+			str := strings.TrimPrefix(field, op)
+			if str == "" && i+1 < len(fields) {
+				// operator separated from the version by a space
+				i++
+				str = fields[i]
+			}
+			version, n, err := parseVersion(str, true)
+			if err != nil {
+				return nil, errors.New("invalid constraint '" + s + "'")
+			}
+			checks = append(checks, versionCheck{op, version, n})
+		}
+		if len(checks) == 0 {
+			return nil, errors.New("invalid constraint '" + s + "'")
+		}
+		constraint = append(constraint, checks)
+	}
+	return constraint, nil
+}
+
+// Check returns true if the version satisfies the constraint
+func (c Constraint) Check(v Version) bool {
+	for _, checks := range c {
+		ok := true
+		for _, check := range checks {
+			if !check.check(v) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionCheck) check(v Version) bool {
+	lower := c.version
+	switch c.op {
+	case "", "=", "==":
+		return c.inRange(v, c.n-1)
+	case "!=":
+		return !c.inRange(v, c.n-1)
+	case ">":
+		return c.n > 0 && v.Compare(increment(lower, c.n-1)) >= 0
+	case ">=":
+		return v.Compare(lower) >= 0
+	case "<":
+		return v.Compare(lower) < 0
+	case "<=":
+		return c.n == 0 || v.Compare(increment(lower, c.n-1)) < 0
+	case "~":
+		// ~1.2.3 allows patch updates, ~1 allows minor updates
+		idx := c.n - 1
+		if idx > 1 {
+			idx = 1
+		}
+		return c.inRange(v, idx)
+	case "^":
+		// ^1.2.3 allows updates that do not change the left-most non-zero part
+		idx := c.n - 1
+		for i := 0; i < c.n; i++ {
+			if lower.Parts[i] != 0 {
+				idx = i
+				break
+			}
+		}
+		return c.inRange(v, idx)
+	}
+	return false
+}
+
+// inRange returns true if v is at least the version and is less than
+// the version with the part at idx incremented, idx < 0 matches any version
+func (c versionCheck) inRange(v Version, idx int) bool {
+	if idx < 0 {
+		return true
+	}
+	return v.Compare(c.version) >= 0 && v.Compare(increment(c.version, idx)) < 0
+}
+
+func increment(v Version, idx int) Version {
+	parts := make([]int, idx+1)
+	copy(parts, v.Parts)
+	parts[idx]++
+	return Version{Parts: parts}
+}
+
+// CheckConstraint returns true if the version satisfies the constraint
+func CheckConstraint(constraint string, version string) (bool, error) {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false, err
+	}
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(v), nil
+}
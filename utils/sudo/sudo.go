@@ -2,11 +2,13 @@ package sudo
 
 import (
 	"bytes"
+	"errors"
 	"github.com/jcwillox/dotbot/store"
 	"golang.org/x/sys/execabs"
 	"gopkg.in/yaml.v3"
 	"log"
 	"os"
+	"os/exec"
 	"syscall"
 )
 
@@ -14,6 +16,10 @@ func IsRoot() bool {
 	return os.Getegid() == 0
 }
 
+// FailedExitCode is the exit code of dotbot when it is run using
+// sudo and any of the directives it was given fail
+const FailedExitCode = 3
+
 var (
 	HasUsedSudo = false
 	canSudo     = -1
@@ -51,8 +57,9 @@ func Configs(configs interface{}) error {
 	}
 
 	vars := store.GetVars()
-	if len(vars) > 0 {
-		configs = map[string]interface{}{"config": configs, "vars": vars}
+	defs := store.GetTmplDefs()
+	if len(vars) > 0 || len(defs) > 0 {
+		configs = map[string]interface{}{"config": configs, "vars": vars, "templates": defs}
 	}
 
 	path, err := os.Executable()
@@ -64,6 +71,9 @@ func Configs(configs interface{}) error {
 	cmd.Stderr = os.Stderr
 	cmd.Stdout = os.Stdout
 	cmd.Env = append(os.Environ(), "DOTBOT_SUDO=true")
+	if store.Adopt {
+		cmd.Env = append(cmd.Env, "DOTBOT_ADOPT=true")
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -90,6 +100,11 @@ func Configs(configs interface{}) error {
 
 	stdin.Close()
 	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == FailedExitCode {
+			// the errors have already been printed by dotbot
+			return errors.New("some tasks failed when run as root")
+		}
 		return err
 	}
 	HasUsedSudo = true
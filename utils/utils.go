@@ -6,7 +6,6 @@ import (
 	"github.com/jcwillox/dotbot/log"
 	"github.com/jcwillox/dotbot/store"
 	"github.com/jcwillox/dotbot/utils/sudo"
-	"github.com/shirou/gopsutil/host"
 	"golang.org/x/sys/execabs"
 	"os"
 	"path/filepath"
@@ -50,8 +49,9 @@ func GetConfigPath() string {
 	return ""
 }
 
+// ChBaseDir changes to the dotfiles directory being applied
 func ChBaseDir() error {
-	if base, present := store.HasGet("directory"); present {
+	if base := store.BaseDir(); base != "" {
 		err := os.Chdir(base)
 		if err != nil {
 			return errors.New("unable to access dotfiles directory: " + err.Error())
@@ -180,22 +180,16 @@ func FormatDuration(d time.Duration) string {
 	return d.Round(scale / 100).String()
 }
 
-var platform string
-var family string
-
-func GetPlatformInfo() (string, string) {
-	if platform != "" {
-		return platform, family
-	}
-	platform, family, _, _ = host.PlatformInformation()
-	return platform, family
+func ArrContains(arr []string, s string) bool {
+	return IndexOf(arr, s) >= 0
 }
 
-func ArrContains(arr []string, s string) bool {
-	for _, s2 := range arr {
+// IndexOf returns the index of s in arr or -1 if it is not present
+func IndexOf(arr []string, s string) int {
+	for i, s2 := range arr {
 		if s == s2 {
-			return true
+			return i
 		}
 	}
-	return false
+	return -1
 }
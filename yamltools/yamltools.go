@@ -215,3 +215,27 @@ func ParseBoolNode(n *yaml.Node) (value bool, ok bool) {
 	}
 	return false, false
 }
+
+// MapValue returns the value of key in a mapping node or nil if not present
+func MapValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i < len(n.Content)-1; i += 2 {
+			if n.Content[i].Value == key {
+				return n.Content[i+1]
+			}
+		}
+	}
+	return nil
+}
+
+// CopyNode returns a deep copy of n, alias targets are shared with the original
+func CopyNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	if n.Content != nil {
+		c.Content = make([]*yaml.Node, len(n.Content))
+		for i, child := range n.Content {
+			c.Content[i] = CopyNode(child)
+		}
+	}
+	return &c
+}